@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/vahid-haghighat/oktaws/internal"
+
+	"github.com/spf13/cobra"
+)
+
+var appsOutput string
+
+var appsCmd = &cobra.Command{
+	Use:   "apps",
+	Short: "List your AWS apps in Okta",
+	Long: `Sign in with the OIDC device flow and list every AWS Account Federation app assigned to you,
+marking the ones the configuration uses. Needs org_domain and oidc_client_id.`,
+	RunE: runApps,
+}
+
+func init() {
+	appsCmd.Flags().StringVarP(&appsOutput, "output", "o", "table", "Output format: table, json, or yaml")
+}
+func runApps(cmd *cobra.Command, args []string) error {
+	cfg, err := internal.NewConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.OrgDomain == "" {
+		return fmt.Errorf("org-domain is required (or set OKTA_AWSCLI_ORG_DOMAIN or run 'oktaws config init')")
+	}
+	if err := resolveOrgDomain(cfg); err != nil {
+		return err
+	}
+	auth := internal.NewAuthenticator(cfg)
+	return auth.ListApps(appsOutput)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/vahid-haghighat/oktaws/internal"
+
+	"github.com/spf13/cobra"
+)
+
+var assumeCmd = &cobra.Command{
+	Use:   "assume",
+	Short: "Assume a role using a SAMLResponse you provide",
+	Long: `Read a SAMLResponse from --saml-file, stdin, or an interactive paste prompt and exchange it
+for AWS credentials. Accepts raw base64 or the entire saved HTML form page.
+
+Use this when the browser extension cannot capture the assertion, or to feed oktaws from
+other SAML tooling.`,
+	RunE:         runAssume,
+	SilenceUsage: true,
+}
+
+func runAssume(cmd *cobra.Command, args []string) error {
+	cfg, err := internal.NewConfig()
+	if err != nil {
+		return err
+	}
+	if err := internal.CheckSystemPolicy("auth_flow", "manual"); err != nil {
+		return err
+	}
+	cfg.AuthFlow = "manual"
+	auth := internal.NewAuthenticator(cfg)
+	return auth.Authenticate()
+}
@@ -1,33 +1,45 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/vahid-haghighat/oktaws/internal"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage oktaws configuration",
-	Long:  `Manage oktaws configuration settings stored in ~/.config/oktaws/config.yaml`,
+	Long:  `Manage oktaws configuration settings stored in $XDG_CONFIG_HOME/oktaws/config.yaml (~/.config/oktaws/config.yaml by default); run 'oktaws config path' to see which file is in use`,
 }
 var configInitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize configuration interactively",
-	Long:  `Interactive setup wizard to configure oktaws`,
-	RunE:  runConfigInit,
+	Long: `Interactive setup wizard to configure oktaws.
+
+With --non-interactive, --seed or --from-embed-link no questions are asked: settings come from the seed file,
+the embed link and any flags such as --org-domain, and re-running with the same inputs leaves the file unchanged.`,
+	SilenceUsage: true,
+	RunE:         runConfigInit,
 }
 var configSetCmd = &cobra.Command{
-	Use:   "set <key> <value>",
-	Short: "Set a configuration value",
-	Long:  `Set a specific configuration value`,
-	Args:  cobra.ExactArgs(2),
-	RunE:  runConfigSet,
+	Use:          "set <key> <value>",
+	Short:        "Set a configuration value",
+	Long:         `Set a specific configuration value`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE:         runConfigSet,
 }
 var configGetCmd = &cobra.Command{
 	Use:   "get <key>",
@@ -36,12 +48,64 @@ var configGetCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	RunE:  runConfigGet,
 }
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a configuration value",
+	Long:  `Remove a configuration value so that it falls back to the inherited value or default`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigUnset,
+}
+var configEditCmd = &cobra.Command{
+	Use:          "edit",
+	Short:        "Edit the configuration file in $EDITOR",
+	Long:         `Open the configuration file in $VISUAL or $EDITOR and validate it before saving`,
+	SilenceUsage: true,
+	RunE:         runConfigEdit,
+}
 var configListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all configuration",
 	Long:  `Display all configuration settings`,
 	RunE:  runConfigList,
 }
+var configUseContextCmd = &cobra.Command{
+	Use:   "use-context <name>",
+	Short: "Switch the current context",
+	Long:  `Set current_context in the configuration file so that later commands use the named context`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigUseContext,
+}
+var configContextsCmd = &cobra.Command{
+	Use:   "contexts",
+	Short: "List configured contexts",
+	Long:  `List the named contexts in the configuration file, marking the current one`,
+	RunE:  runConfigContexts,
+}
+var configExplainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Show every effective setting and where it came from",
+	Long:  `Show every effective setting with its source: config file, context, AWS profile, environment variable, flag or built-in default`,
+	RunE:  runConfigExplain,
+}
+var configValidateCmd = &cobra.Command{
+	Use:          "validate",
+	Short:        "Check the configuration file for mistakes",
+	Long:         `Check the configuration file for unknown keys, wrong types and invalid values, reporting each problem with its line number`,
+	SilenceUsage: true,
+	RunE:         runConfigValidate,
+}
+var configImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import settings from gimme-aws-creds, saml2aws or okta-aws-cli",
+	Long: `Translate another tool's profiles into oktaws contexts and report every setting that could not be carried over.
+
+Sources:
+  gimme-aws-creds  ~/.okta_aws_login_config, one context per profile
+  saml2aws         ~/.saml2aws, one context per Okta account
+  okta-aws-cli     OKTA_AWSCLI_* from ./.env and the environment, plus ~/.okta/okta.yaml`,
+	SilenceUsage: true,
+	RunE:         runConfigImport,
+}
 var configPathCmd = &cobra.Command{
 	Use:   "path",
 	Short: "Show configuration file path",
@@ -53,10 +117,41 @@ func init() {
 	configCmd.AddCommand(configInitCmd)
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configEditCmd)
 	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configUseContextCmd)
+	configCmd.AddCommand(configContextsCmd)
+	configCmd.AddCommand(configExplainCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configImportCmd)
 	configCmd.AddCommand(configPathCmd)
+	configInitCmd.Flags().Bool("non-interactive", false, "Do not prompt; take settings from flags, --seed and --from-embed-link")
+	configInitCmd.Flags().String("seed", "", "YAML or JSON file in the config.yaml layout to initialise from")
+	configInitCmd.Flags().String("from-embed-link", "", "Okta app embed link (https://your-org.okta.com/home/amazon_aws/0oa.../272) to take the org domain and app ID from")
+	configListCmd.Flags().StringP("output", "o", "table", "Output format: table, json, or yaml")
+	configListCmd.Flags().Bool("effective", false, "Show effective values with flags, environment and AWS profile settings applied")
+	configExplainCmd.Flags().StringP("output", "o", "table", "Output format: table, json, or yaml")
+	configValidateCmd.Flags().String("file", "", "Config file to validate (default: the oktaws config file)")
+	configImportCmd.Flags().String("from", "", "Tool to import from: "+strings.Join(internal.ImportSources, ", "))
+	configImportCmd.Flags().String("file", "", "Read this file instead of the tool's default location")
+	configImportCmd.Flags().Bool("overwrite", false, "Replace existing contexts with the same names")
+	configImportCmd.Flags().Bool("dry-run", false, "Show the resulting configuration without writing it")
+	configImportCmd.MarkFlagRequired("from")
+	configValidateCmd.Flags().Bool("check-connectivity", false, "Also make read-only requests to each Okta org and app (no login)")
 }
 func runConfigInit(cmd *cobra.Command, args []string) error {
+	seedPath, _ := cmd.Flags().GetString("seed")
+	embedLink, _ := cmd.Flags().GetString("from-embed-link")
+	nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
+	if seedPath != "" || embedLink != "" || nonInteractive {
+		return runConfigInitNonInteractive(seedPath, embedLink)
+	}
+	current := &internal.Config{}
+	if effective, err := internal.NewConfig(); err == nil {
+		current = effective
+	}
+	reader := bufio.NewReader(os.Stdin)
 	fmt.Println("Oktaws Configuration Setup")
 	fmt.Println("==========================")
 	fmt.Println()
@@ -65,72 +160,403 @@ func runConfigInit(cmd *cobra.Command, args []string) error {
 	fmt.Println("  1. auto     - Auto-detect based on available configuration (recommended)")
 	fmt.Println("  2. oidc     - OIDC device authorization flow")
 	fmt.Println("  3. saml-browser - Browser-based SAML flow")
-	fmt.Print("Choice [1]: ")
-	var choice string
-	fmt.Scanln(&choice)
-	switch choice {
+	fmt.Println("  4. sso      - IAM Identity Center with Okta as its identity source")
+	switch promptLine(reader, "Choice [1]: ") {
 	case "2":
 		cfg.AuthFlow = "oidc"
 	case "3":
 		cfg.AuthFlow = "saml-browser"
+	case "4":
+		cfg.AuthFlow = "sso"
+		return runConfigInitIdentityCenter(reader, cfg, current)
 	default:
 		cfg.AuthFlow = "auto"
 	}
-	fmt.Print("\nOkta organization domain (e.g., company.okta.com): ")
-	fmt.Scanln(&cfg.OrgDomain)
+	cfg.OrgDomain = promptDefault(reader, "\nOkta organization domain (e.g., company.okta.com) or your work email", current.OrgDomain)
+	for internal.IsEmailAddress(cfg.OrgDomain) {
+		if cfg.OrgDiscoveryDomain = current.OrgDiscoveryDomain; cfg.OrgDiscoveryDomain == "" {
+			cfg.OrgDiscoveryDomain = promptLine(reader, "Any Okta domain of your organisation to look your org up from (e.g., company.okta.com): ")
+		}
+		err := resolveOrgDomain(cfg)
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		current.OrgDiscoveryDomain = ""
+		cfg.OrgDomain = promptLine(reader, "Okta organization domain or your work email: ")
+	}
 	if cfg.AuthFlow == "oidc" || cfg.AuthFlow == "auto" {
-		fmt.Print("OIDC Client ID (optional, press Enter to skip): ")
-		fmt.Scanln(&cfg.OIDCClientID)
-	}
-	fmt.Print("AWS Account Federation App ID (e.g., exk123...): ")
-	fmt.Scanln(&cfg.AWSAcctFedAppID)
-	fmt.Print("AWS IAM Role ARN (optional, press Enter to skip): ")
-	fmt.Scanln(&cfg.AWSIAMRole)
-	fmt.Print("AWS Region [us-east-1]: ")
-	fmt.Scanln(&cfg.AWSRegion)
-	if cfg.AWSRegion == "" {
+		cfg.OIDCClientID = promptDefault(reader, "OIDC Client ID (lets oktaws find your AWS apps; press Enter to skip)", current.OIDCClientID)
+	}
+
+	var roles *internal.ConfigImport
+	if cfg.OIDCClientID != "" && promptYes(reader, "\nSign in now to find your AWS apps and roles? [Y/n]: ", true) {
+		var err error
+		if roles, err = discoverSetupRoles(reader, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "\nCould not look up your apps and roles: %v\nContinuing with manual setup.\n\n", err)
+			roles = nil
+		}
+	}
+	if roles == nil {
+		cfg.AWSAcctFedAppID = promptLine(reader, "AWS Account Federation App ID or app embed link (e.g., exk123...): ")
+		if strings.Contains(cfg.AWSAcctFedAppID, "://") {
+			orgDomain, appID, err := internal.ParseEmbedLink(cfg.AWSAcctFedAppID)
+			if err != nil {
+				return err
+			}
+			cfg.AWSAcctFedAppID = appID
+			if cfg.OrgDomain == "" {
+				cfg.OrgDomain = orgDomain
+			}
+		}
+		cfg.AWSIAMRole = promptLine(reader, "AWS IAM Role ARN (optional, press Enter to skip): ")
+		if cfg.Profile = promptLine(reader, "AWS Profile name [default]: "); cfg.Profile == "" {
+			cfg.Profile = "default"
+		}
+	}
+	if cfg.AWSRegion = promptLine(reader, "AWS Region [us-east-1]: "); cfg.AWSRegion == "" {
 		cfg.AWSRegion = "us-east-1"
 	}
-	fmt.Print("AWS Profile name [default]: ")
-	fmt.Scanln(&cfg.Profile)
-	if cfg.Profile == "" {
+	if durationStr := promptLine(reader, "Session duration in seconds (optional, press Enter to use the SAML SessionDuration or 3600): "); durationStr != "" {
+		if err := cfg.SetValue("session_duration", durationStr); err != nil {
+			return err
+		}
+	}
+	cfg.OpenBrowser = promptYes(reader, "Automatically open browser? [y/N]: ", false)
+	return saveInitConfig(cfg, func(file *internal.ConfigFile) error {
+		if roles == nil {
+			return nil
+		}
+		if err := file.ApplyImport(roles, true); err != nil {
+			return err
+		}
+		file.CurrentContext = roles.Contexts[0].Name
+		return nil
+	})
+}
+
+// runConfigInitIdentityCenter finishes the wizard for the sso flow, which
+// needs the access portal instead of any Okta app settings.
+func runConfigInitIdentityCenter(reader *bufio.Reader, cfg, current *internal.Config) error {
+	cfg.SSOStartURL = promptDefault(reader, "\nAccess portal URL (e.g., https://my-org.awsapps.com/start)", current.SSOStartURL)
+	if cfg.SSORegion = promptDefault(reader, "IAM Identity Center region", current.SSORegion); cfg.SSORegion == "" {
+		cfg.SSORegion = "us-east-1"
+	}
+	cfg.SSOAccountID = promptLine(reader, "AWS account ID (optional, press Enter to choose at sign-in): ")
+	cfg.SSORoleName = promptLine(reader, "Permission set name (optional, press Enter to choose at sign-in): ")
+	if cfg.Profile = promptLine(reader, "AWS Profile name [default]: "); cfg.Profile == "" {
 		cfg.Profile = "default"
 	}
-	fmt.Print("Session duration in seconds [3600]: ")
-	var durationStr string
-	fmt.Scanln(&durationStr)
-	if durationStr == "" {
-		cfg.SessionDuration = 3600
+	cfg.OpenBrowser = promptYes(reader, "Automatically open browser? [y/N]: ", false)
+	return saveInitConfig(cfg, nil)
+}
+
+// discoverSetupRoles signs in, lets the user pick one of their AWS apps and
+// some of its roles, and returns a context per role with its own profile.
+func discoverSetupRoles(reader *bufio.Reader, cfg *internal.Config) (*internal.ConfigImport, error) {
+	session, err := internal.StartSetupSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := session.AWSApps()
+	if err != nil {
+		return nil, err
+	}
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no AWS apps are assigned to you in %s", cfg.OrgDomain)
+	}
+	app := apps[0]
+	if len(apps) > 1 {
+		fmt.Println("\nAWS apps:")
+		for i, a := range apps {
+			fmt.Printf("  %d. %s (%s)\n", i+1, a.Label, a.ID)
+		}
+		choice, err := internal.ParseSelection(promptLine(reader, "App [1]: "), len(apps), false)
+		if err != nil {
+			return nil, err
+		}
+		app = apps[choice[0]]
+	}
+	cfg.AWSAcctFedAppID = app.ID
+
+	roles, err := session.Roles(app.ID)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("\nRoles in %s:\n", app.Label)
+	roleNames := map[string]int{}
+	for i, role := range roles {
+		fmt.Printf("  %d. %s  %s\n", i+1, role.AccountID, role.RoleName)
+		roleNames[role.RoleName]++
+	}
+	chosen, err := internal.ParseSelection(promptLine(reader, "Roles to set up, e.g. 1,3 or 2-4 [all]: "), len(roles), true)
+	if err != nil {
+		return nil, err
+	}
+	imported := &internal.ConfigImport{Source: "okta"}
+	for _, i := range chosen {
+		role := roles[i]
+		name := role.RoleName
+		if roleNames[name] > 1 {
+			name = role.AccountID + "-" + name
+		}
+		profile := promptDefault(reader, fmt.Sprintf("AWS profile for %s", role.RoleARN), name)
+		imported.Contexts = append(imported.Contexts, internal.ImportedContext{
+			Name:     profile,
+			Inherits: viper.GetString("context"),
+			Config:   internal.Config{AWSIAMRole: role.RoleARN, AWSIAMIdP: role.PrincipalARN, Profile: profile},
+		})
+	}
+	return imported, nil
+}
+
+// runConfigInitNonInteractive builds the configuration from a seed file, an
+// embed link and the command-line flags, in that order, without prompting.
+func runConfigInitNonInteractive(seedPath, embedLink string) error {
+	seed := &internal.ConfigFile{}
+	if seedPath != "" {
+		var err error
+		if seed, err = internal.LoadConfigSeed(seedPath); err != nil {
+			return err
+		}
+	}
+	cfg := seed.Config
+	if embedLink != "" {
+		orgDomain, appID, err := internal.ParseEmbedLink(embedLink)
+		if err != nil {
+			return err
+		}
+		cfg.OrgDomain = orgDomain
+		cfg.AWSAcctFedAppID = appID
+	}
+	if err := cfg.ApplyFlags(); err != nil {
+		return err
+	}
+	if err := resolveOrgDomain(&cfg); err != nil {
+		return err
+	}
+	if cfg.OrgDomain == "" && cfg.AuthFlow != "manual" && cfg.SSOStartURL == "" && len(seed.Contexts) == 0 {
+		return fmt.Errorf("org_domain is required: pass --org-domain, --from-embed-link, --sso-start-url or a --seed file")
+	}
+	return saveInitConfig(&cfg, func(file *internal.ConfigFile) error {
+		for _, name := range seed.ContextNames() {
+			if file.Contexts == nil {
+				file.Contexts = map[string]yaml.Node{}
+			}
+			file.Contexts[name] = seed.Contexts[name]
+		}
+		if seed.CurrentContext != "" {
+			file.CurrentContext = seed.CurrentContext
+		}
+		return nil
+	})
+}
+
+// saveInitConfig writes cfg to the top level, or to the --context context,
+// lets extend (if any) add anything else, and saves the result if it validates.
+func saveInitConfig(cfg *internal.Config, extend func(*internal.ConfigFile) error) error {
+	file, err := internal.LoadOrNewConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	previous, _ := file.Marshal()
+	if contextName := viper.GetString("context"); contextName != "" {
+		if err := file.SetContext(contextName, cfg); err != nil {
+			return err
+		}
+		if file.CurrentContext == "" {
+			file.CurrentContext = contextName
+		}
 	} else {
-		fmt.Sscanf(durationStr, "%d", &cfg.SessionDuration)
+		file.Config = *cfg
 	}
-	fmt.Print("Automatically open browser? [y/N]: ")
-	var openBrowser string
-	fmt.Scanln(&openBrowser)
-	cfg.OpenBrowser = strings.ToLower(openBrowser) == "y" || strings.ToLower(openBrowser) == "yes"
-	if err := cfg.SaveToFile(); err != nil {
-		return fmt.Errorf("failed to save configuration: %w", err)
+	if extend != nil {
+		if err := extend(file); err != nil {
+			return err
+		}
+	}
+	data, err := file.Marshal()
+	if err != nil {
+		return err
+	}
+	if problems := internal.ValidateConfig(data); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%s\n", problem)
+		}
+		return fmt.Errorf("configuration not saved: %d problem(s)", len(problems))
 	}
 	configPath := internal.GetConfigPath()
+	if _, err := os.Stat(configPath); err == nil && bytes.Equal(previous, data) {
+		fmt.Printf("✓ Configuration unchanged: %s\n", configPath)
+		return nil
+	}
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
 	fmt.Printf("\n✓ Configuration saved to: %s\n", configPath)
 	return nil
 }
+func promptLine(reader *bufio.Reader, prompt string) string {
+	fmt.Print(prompt)
+	line, _ := reader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+func promptDefault(reader *bufio.Reader, label, value string) string {
+	if value == "" {
+		return promptLine(reader, label+": ")
+	}
+	if answer := promptLine(reader, fmt.Sprintf("%s [%s]: ", label, value)); answer != "" {
+		return answer
+	}
+	return value
+}
+func promptYes(reader *bufio.Reader, prompt string, fallback bool) bool {
+	switch strings.ToLower(promptLine(reader, prompt)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return fallback
+}
 func runConfigSet(cmd *cobra.Command, args []string) error {
 	key := args[0]
 	value := args[1]
-	cfg, err := internal.LoadConfigFromFile()
+	if err := internal.CheckSystemPolicy(key, value); err != nil {
+		return err
+	}
+	file, contextName, err := loadConfigForUpdate()
 	if err != nil {
-		cfg = &internal.Config{}
+		return err
 	}
-	if err := cfg.SetValue(key, value); err != nil {
+	if contextName != "" {
+		if err := file.SetContextValue(contextName, key, value); err != nil {
+			return err
+		}
+	} else if err := file.SetValue(key, value); err != nil {
 		return err
 	}
-	if err := cfg.SaveToFile(); err != nil {
+	if err := file.Save(); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
+	if contextName != "" {
+		fmt.Printf("✓ Set %s = %s in context %s\n", key, value, contextName)
+		return nil
+	}
 	fmt.Printf("✓ Set %s = %s\n", key, value)
 	return nil
 }
+func runConfigUnset(cmd *cobra.Command, args []string) error {
+	key := args[0]
+	file, contextName, err := loadConfigForUpdate()
+	if err != nil {
+		return err
+	}
+	if contextName != "" {
+		if err := file.UnsetContextValue(contextName, key); err != nil {
+			return err
+		}
+	} else if err := file.UnsetValue(key); err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	if contextName != "" {
+		fmt.Printf("✓ Unset %s in context %s\n", key, contextName)
+		return nil
+	}
+	fmt.Printf("✓ Unset %s\n", key)
+	return nil
+}
+
+// loadConfigForUpdate returns the config file and the context that set and
+// unset should write to: --context, else current_context, else top level.
+func loadConfigForUpdate() (*internal.ConfigFile, string, error) {
+	file, err := internal.LoadOrNewConfigFile()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load configuration: %w", err)
+	}
+	contextName := viper.GetString("context")
+	if contextName == "" {
+		contextName = file.CurrentContext
+	}
+	return file, contextName, nil
+}
+func runConfigEdit(cmd *cobra.Command, args []string) error {
+	configPath := internal.GetConfigPath()
+	original, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), "config-edit-*.yaml")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(original)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	for {
+		if err := runEditor(tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		edited, err := os.ReadFile(tmpPath)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, original) {
+			os.Remove(tmpPath)
+			fmt.Println("No changes made.")
+			return nil
+		}
+		problems := internal.ValidateConfig(edited)
+		if len(problems) == 0 {
+			if err := os.WriteFile(configPath, edited, 0600); err != nil {
+				return fmt.Errorf("failed to save configuration: %w", err)
+			}
+			os.Remove(tmpPath)
+			fmt.Printf("✓ Configuration saved to: %s\n", configPath)
+			return nil
+		}
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%s\n", problem)
+		}
+		fmt.Print("Re-open the editor to fix these problems? [Y/n]: ")
+		var answer string
+		fmt.Scanln(&answer)
+		if strings.HasPrefix(strings.ToLower(answer), "n") {
+			return fmt.Errorf("configuration not saved; your edits are in %s", tmpPath)
+		}
+	}
+}
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	parts := strings.Fields(editor)
+	editorCmd := exec.Command(parts[0], append(parts[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
 func runConfigGet(cmd *cobra.Command, args []string) error {
 	key := args[0]
 	cfg, err := internal.LoadConfigFromFile()
@@ -145,22 +571,163 @@ func runConfigGet(cmd *cobra.Command, args []string) error {
 	return nil
 }
 func runConfigList(cmd *cobra.Command, args []string) error {
-	cfg, err := internal.LoadConfigFromFile()
+	format, _ := cmd.Flags().GetString("output")
+	effective, _ := cmd.Flags().GetBool("effective")
+	var cfg *internal.Config
+	var err error
+	if effective {
+		cfg, err = internal.NewConfig()
+	} else {
+		cfg, err = internal.LoadConfigFromFile()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return internal.PrintConfig(cfg, activeContextName(), format)
+}
+func runConfigUseContext(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	file, err := internal.LoadConfigFile()
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
-	fmt.Println("Current Configuration:")
-	fmt.Println("=====================")
-	fmt.Printf("auth_flow:            %s\n", cfg.AuthFlow)
-	fmt.Printf("org_domain:           %s\n", cfg.OrgDomain)
-	fmt.Printf("oidc_client_id:       %s\n", cfg.OIDCClientID)
-	fmt.Printf("aws_acct_fed_app_id:  %s\n", cfg.AWSAcctFedAppID)
-	fmt.Printf("aws_iam_role:         %s\n", cfg.AWSIAMRole)
-	fmt.Printf("aws_region:           %s\n", cfg.AWSRegion)
-	fmt.Printf("profile:              %s\n", cfg.Profile)
-	fmt.Printf("session_duration:     %d\n", cfg.SessionDuration)
-	fmt.Printf("open_browser:         %t\n", cfg.OpenBrowser)
-	fmt.Printf("debug:                %t\n", cfg.Debug)
+	if _, err := file.Resolve(name); err != nil {
+		return err
+	}
+	file.CurrentContext = name
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	fmt.Printf("✓ Switched to context %s\n", name)
+	return nil
+}
+func runConfigContexts(cmd *cobra.Command, args []string) error {
+	file, err := internal.LoadConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	current := activeContextName()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tINHERITS\tORG DOMAIN\tPROFILE")
+	for _, name := range file.ContextNames() {
+		marker := ""
+		if name == current {
+			marker = "*"
+		}
+		orgDomain, profile := "", ""
+		if cfg, err := file.Resolve(name); err == nil {
+			orgDomain, profile = cfg.OrgDomain, cfg.Profile
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", marker, name, file.ContextParent(name), orgDomain, profile)
+	}
+	return w.Flush()
+}
+func activeContextName() string {
+	if name := viper.GetString("context"); name != "" {
+		return name
+	}
+	if file, err := internal.LoadConfigFile(); err == nil {
+		return file.CurrentContext
+	}
+	return ""
+}
+func runConfigExplain(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("output")
+	return internal.ExplainConfig(format)
+}
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	path, _ := cmd.Flags().GetString("file")
+	if path == "" {
+		path = internal.GetConfigPath()
+	}
+	problems, migrations, err := internal.ValidateConfigFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+	for _, step := range migrations {
+		fmt.Printf("Pending migration %s\n", step)
+	}
+	var file internal.ConfigFile
+	if len(problems) == 0 {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return err
+		}
+		if checkConnectivity, _ := cmd.Flags().GetBool("check-connectivity"); checkConnectivity {
+			problems = append(problems, internal.CheckConnectivity(&file)...)
+		}
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s has %d problem(s)", path, len(problems))
+	}
+	for _, note := range internal.ConfigNotes(&file) {
+		fmt.Printf("Note: %s\n", note)
+	}
+	fmt.Printf("✓ %s is valid\n", path)
+	return nil
+}
+func runConfigImport(cmd *cobra.Command, args []string) error {
+	from, _ := cmd.Flags().GetString("from")
+	path, _ := cmd.Flags().GetString("file")
+	overwrite, _ := cmd.Flags().GetBool("overwrite")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	imported, err := internal.ImportConfig(from, path)
+	if err != nil {
+		return err
+	}
+	if contextName := viper.GetString("context"); contextName != "" {
+		if len(imported.Contexts) != 1 {
+			return fmt.Errorf("--context can only rename a single imported profile; %s has %d", imported.Path, len(imported.Contexts))
+		}
+		imported.Contexts[0].Name = contextName
+	}
+	file, err := internal.LoadOrNewConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := file.ApplyImport(imported, overwrite); err != nil {
+		return err
+	}
+	data, err := file.Marshal()
+	if err != nil {
+		return err
+	}
+	if problems := internal.ValidateConfig(data); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%s\n", problem)
+		}
+		return fmt.Errorf("configuration not saved: %d problem(s); fix them in %s or re-run with --dry-run to inspect", len(problems), imported.Path)
+	}
+	if dryRun {
+		os.Stdout.Write(data)
+	} else if err := file.Save(); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	var names []string
+	for _, ctx := range imported.Contexts {
+		names = append(names, ctx.Name)
+	}
+	verb := "Imported"
+	if dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("✓ %s %d context(s) from %s: %s\n", verb, len(names), from, strings.Join(names, ", "))
+	if file.CurrentContext != "" {
+		fmt.Printf("  current context: %s (switch with 'oktaws config use-context <name>')\n", file.CurrentContext)
+	}
+	if len(imported.Untranslated) > 0 {
+		fmt.Printf("\nNot translated (%d):\n", len(imported.Untranslated))
+		for _, line := range imported.Untranslated {
+			fmt.Printf("  %s\n", line)
+		}
+	}
 	return nil
 }
 func runConfigPath(cmd *cobra.Command, args []string) error {
@@ -172,10 +739,3 @@ func runConfigPath(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
-func getConfigDir() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-	return filepath.Join(homeDir, ".config", "oktaws"), nil
-}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/vahid-haghighat/oktaws/internal"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var direnvCmd = &cobra.Command{
+	Use:   "direnv",
+	Short: "Print the direnv hook for project-local credentials",
+	Long: `Print a direnvrc function that makes "use oktaws" in a project's .envrc export credentials
+for the profile declared in the project's .oktaws.yaml. Add the output to ~/.config/direnv/direnvrc.`,
+	RunE: runDirenv,
+}
+var direnvExportCmd = &cobra.Command{
+	Use:   "export [context]",
+	Short: "Print shell exports for the project's profile",
+	Long: `Print AWS credential exports for the profile declared in the nearest .oktaws.yaml, signing in
+only when the session cached in the credentials file has expired. Used by "use oktaws".`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runDirenvExport,
+}
+
+func init() {
+	direnvCmd.AddCommand(direnvExportCmd)
+}
+func runDirenv(cmd *cobra.Command, args []string) error {
+	fmt.Print(internal.DirenvHook)
+	return nil
+}
+func runDirenvExport(cmd *cobra.Command, args []string) error {
+	if internal.FindProjectConfig() == "" {
+		return fmt.Errorf("no %s found between here and the repository root", internal.ProjectConfigFileName)
+	}
+	if len(args) == 1 {
+		viper.Set("context", args[0])
+	}
+	cfg, err := loadAuthConfig()
+	if err != nil {
+		return err
+	}
+	return internal.DirenvExport(cfg)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/vahid-haghighat/oktaws/internal"
+
+	"github.com/spf13/cobra"
+)
+
+var rolesOutput string
+
+var rolesCmd = &cobra.Command{
+	Use:   "roles",
+	Short: "List available AWS roles",
+	Long: `Authenticate with Okta and list every role/principal pair in the SAML assertion
+without assuming any of them.`,
+	RunE: runRoles,
+}
+
+func init() {
+	rolesCmd.Flags().StringVarP(&rolesOutput, "output", "o", "table", "Output format: table, json, or yaml")
+}
+func runRoles(cmd *cobra.Command, args []string) error {
+	cfg, err := loadAuthConfig()
+	if err != nil {
+		return err
+	}
+	auth := internal.NewAuthenticator(cfg)
+	return auth.ListRoles(rolesOutput)
+}
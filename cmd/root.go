@@ -32,12 +32,32 @@ func runWebAuth(cmd *cobra.Command, args []string) error {
 		fmt.Println(version.Version)
 		return nil
 	}
-	cfg, err := internal.NewConfig()
+	cfg, err := loadAuthConfig()
 	if err != nil {
 		return err
 	}
+	auth := internal.NewAuthenticator(cfg)
+	return auth.Authenticate()
+}
+func loadAuthConfig() (*internal.Config, error) {
+	cfg, err := internal.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AuthFlow == "manual" || (cfg.AuthFlow == "auto" && cfg.SAMLFile != "") {
+		return cfg, nil
+	}
+	if cfg.AuthFlow == "sso" || (cfg.AuthFlow == "auto" && cfg.SSOStartURL != "") {
+		if cfg.SSOStartURL == "" {
+			return nil, fmt.Errorf("sso-start-url is required for the sso flow (or run 'oktaws config set sso_start_url https://my-org.awsapps.com/start')")
+		}
+		return cfg, nil
+	}
 	if cfg.OrgDomain == "" {
-		return fmt.Errorf("org-domain is required (or set OKTA_AWSCLI_ORG_DOMAIN or run 'oktaws config init')")
+		return nil, fmt.Errorf("org-domain is required (or set OKTA_AWSCLI_ORG_DOMAIN or run 'oktaws config init')")
+	}
+	if err := resolveOrgDomain(cfg); err != nil {
+		return nil, err
 	}
 	authFlow := cfg.AuthFlow
 	if authFlow == "auto" {
@@ -48,51 +68,74 @@ func runWebAuth(cmd *cobra.Command, args []string) error {
 		}
 	}
 	if authFlow == "oidc" && cfg.OIDCClientID == "" {
-		return fmt.Errorf("oidc-client-id is required for OIDC flow (or set OKTA_AWSCLI_OIDC_CLIENT_ID)")
+		return nil, fmt.Errorf("oidc-client-id is required for OIDC flow (or set OKTA_AWSCLI_OIDC_CLIENT_ID)")
 	}
 	if authFlow == "saml-browser" && cfg.AWSAcctFedAppID == "" {
-		return fmt.Errorf("aws-acct-fed-app-id is required for browser SAML flow (run 'oktaws config init' to configure)")
+		return nil, fmt.Errorf("aws-acct-fed-app-id is required for browser SAML flow (run 'oktaws config init' to configure)")
 	}
-	auth := internal.NewAuthenticator(cfg)
-	return auth.Authenticate()
+	return cfg, nil
 }
 func init() {
 	rootCmd.AddCommand(configCmd)
-	rootCmd.PersistentFlags().StringP("auth-flow", "x", "", "Authentication flow: auto, oidc, or saml-browser (default: auto)")
-	rootCmd.PersistentFlags().StringP("org-domain", "o", os.Getenv("OKTA_AWSCLI_ORG_DOMAIN"), "Okta organization domain")
+	rootCmd.AddCommand(assumeCmd)
+	rootCmd.AddCommand(rolesCmd)
+	rootCmd.AddCommand(whoamiCmd)
+	rootCmd.AddCommand(samlCmd)
+	rootCmd.AddCommand(direnvCmd)
+	rootCmd.AddCommand(appsCmd)
+	rootCmd.PersistentFlags().String("context", os.Getenv("OKTA_AWSCLI_CONTEXT"), "Named configuration context to use (default: current_context from the config file)")
+	rootCmd.PersistentFlags().StringP("auth-flow", "x", "", "Authentication flow: auto, oidc, saml-browser, manual, or sso (default: auto)")
+	rootCmd.PersistentFlags().StringP("org-domain", "O", os.Getenv("OKTA_AWSCLI_ORG_DOMAIN"), "Okta organization domain, or your work email to look it up")
+	rootCmd.PersistentFlags().String("org-discovery-domain", "", "Any Okta domain of your organisation, used to look up the org from an email address")
 	rootCmd.PersistentFlags().StringP("oidc-client-id", "c", os.Getenv("OKTA_AWSCLI_OIDC_CLIENT_ID"), "OIDC client ID")
 	rootCmd.PersistentFlags().StringP("aws-iam-role", "r", os.Getenv("OKTA_AWSCLI_IAM_ROLE"), "AWS IAM role ARN")
+	rootCmd.PersistentFlags().StringSlice("role-chain", nil, "Role ARNs to chain into with sts:AssumeRole after the SAML assume, in order")
+	rootCmd.PersistentFlags().String("session-policy-file", os.Getenv("OKTA_AWSCLI_SESSION_POLICY_FILE"), "JSON file with an inline session policy to downscope the session")
+	rootCmd.PersistentFlags().StringSlice("session-policy-arns", nil, "Managed policy ARNs to downscope the session")
+	rootCmd.PersistentFlags().Bool("read-only-profile", false, "Also write a companion <profile>-readonly profile scoped by ReadOnlyAccess")
 	rootCmd.PersistentFlags().StringP("aws-iam-idp", "i", os.Getenv("OKTA_AWSCLI_IAM_IDP"), "AWS IAM identity provider ARN")
 	rootCmd.PersistentFlags().StringP("aws-acct-fed-app-id", "a", os.Getenv("OKTA_AWSCLI_AWS_ACCOUNT_FEDERATION_APP_ID"), "AWS Account Federation app ID")
+	rootCmd.PersistentFlags().StringSlice("aws-acct-fed-app-ids", nil, "Several AWS Account Federation app IDs whose roles are offered together (OIDC flow)")
+	rootCmd.PersistentFlags().Bool("all-apps", false, "Offer the roles of every AWS app assigned to you (OIDC flow)")
+	rootCmd.PersistentFlags().String("sso-start-url", "", "IAM Identity Center access portal URL (sso flow)")
+	rootCmd.PersistentFlags().String("sso-region", "", "Region of the IAM Identity Center instance (default: aws-region)")
+	rootCmd.PersistentFlags().String("sso-account-id", "", "AWS account to sign in to with the sso flow")
+	rootCmd.PersistentFlags().String("sso-role-name", "", "Permission set to use with the sso flow")
 	rootCmd.PersistentFlags().StringP("profile", "p", os.Getenv("OKTA_AWSCLI_PROFILE"), "AWS profile name")
 	rootCmd.PersistentFlags().StringP("aws-session-duration", "s", os.Getenv("OKTA_AWSCLI_SESSION_DURATION"), "Session duration")
 	rootCmd.PersistentFlags().StringP("format", "f", os.Getenv("OKTA_AWSCLI_FORMAT"), "Output format")
 	rootCmd.PersistentFlags().StringP("aws-region", "n", os.Getenv("OKTA_AWSCLI_AWS_REGION"), "AWS region")
+	rootCmd.PersistentFlags().String("sts-endpoint-mode", os.Getenv("OKTA_AWSCLI_STS_ENDPOINT_MODE"), "STS endpoint mode: global or regional")
+	rootCmd.PersistentFlags().Bool("use-fips-endpoint", false, "Use FIPS STS endpoints")
+	rootCmd.PersistentFlags().String("sts-endpoint", os.Getenv("OKTA_AWSCLI_STS_ENDPOINT"), "Override the STS endpoint URL (e.g. a LocalStack instance)")
 	rootCmd.PersistentFlags().BoolP("qr-code", "q", false, "Display QR code")
 	rootCmd.PersistentFlags().BoolP("open-browser", "b", false, "Open browser automatically")
 	rootCmd.PersistentFlags().StringP("open-browser-command", "m", os.Getenv("OKTA_AWSCLI_BROWSER_COMMAND"), "Browser command")
 	rootCmd.PersistentFlags().BoolP("all-profiles", "k", false, "Collect all profiles")
 	rootCmd.PersistentFlags().BoolP("write-aws-credentials", "w", false, "Write to ~/.aws/credentials")
 	rootCmd.PersistentFlags().BoolP("cache-access-token", "e", false, "Cache access token")
+	rootCmd.PersistentFlags().String("saml-idp-cert", os.Getenv("OKTA_AWSCLI_SAML_IDP_CERT"), "PEM file with the Okta app's IdP signing certificate (default: fetched from app metadata)")
+	rootCmd.PersistentFlags().String("saml-file", "", "Read the SAMLResponse (base64 or saved HTML page) from a file, or - for stdin")
+	rootCmd.PersistentFlags().Bool("skip-saml-validation", false, "Skip local SAML signature, validity and replay checks")
 	rootCmd.PersistentFlags().BoolP("debug", "g", false, "Debug mode")
 	rootCmd.PersistentFlags().BoolP("debug-api-calls", "d", false, "Debug API calls")
 	rootCmd.Flags().BoolVarP(&versionFlag, "version", "v", false, "Prints oktaws' version")
-	viper.BindPFlag("auth-flow", rootCmd.PersistentFlags().Lookup("auth-flow"))
-	viper.BindPFlag("org-domain", rootCmd.PersistentFlags().Lookup("org-domain"))
-	viper.BindPFlag("oidc-client-id", rootCmd.PersistentFlags().Lookup("oidc-client-id"))
-	viper.BindPFlag("aws-iam-role", rootCmd.PersistentFlags().Lookup("aws-iam-role"))
-	viper.BindPFlag("aws-iam-idp", rootCmd.PersistentFlags().Lookup("aws-iam-idp"))
-	viper.BindPFlag("aws-acct-fed-app-id", rootCmd.PersistentFlags().Lookup("aws-acct-fed-app-id"))
-	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
-	viper.BindPFlag("aws-session-duration", rootCmd.PersistentFlags().Lookup("aws-session-duration"))
-	viper.BindPFlag("format", rootCmd.PersistentFlags().Lookup("format"))
-	viper.BindPFlag("aws-region", rootCmd.PersistentFlags().Lookup("aws-region"))
-	viper.BindPFlag("qr-code", rootCmd.PersistentFlags().Lookup("qr-code"))
-	viper.BindPFlag("open-browser", rootCmd.PersistentFlags().Lookup("open-browser"))
-	viper.BindPFlag("open-browser-command", rootCmd.PersistentFlags().Lookup("open-browser-command"))
-	viper.BindPFlag("all-profiles", rootCmd.PersistentFlags().Lookup("all-profiles"))
-	viper.BindPFlag("write-aws-credentials", rootCmd.PersistentFlags().Lookup("write-aws-credentials"))
-	viper.BindPFlag("cache-access-token", rootCmd.PersistentFlags().Lookup("cache-access-token"))
-	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
-	viper.BindPFlag("debug-api-calls", rootCmd.PersistentFlags().Lookup("debug-api-calls"))
+	internal.BindFlags(rootCmd.PersistentFlags())
+	viper.BindPFlag("context", rootCmd.PersistentFlags().Lookup("context"))
+	viper.BindPFlag("saml-file", rootCmd.PersistentFlags().Lookup("saml-file"))
+}
+
+// resolveOrgDomain replaces an email address given as the org domain with the
+// Okta org that signs it in.
+func resolveOrgDomain(cfg *internal.Config) error {
+	if !internal.IsEmailAddress(cfg.OrgDomain) {
+		return nil
+	}
+	orgDomain, err := internal.DiscoverOrgDomain(cfg.OrgDomain, cfg.OrgDiscoveryDomain, cfg.DebugAPICalls)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✓ %s signs in with Okta org %s\n", cfg.OrgDomain, orgDomain)
+	cfg.OrgDomain = orgDomain
+	return nil
 }
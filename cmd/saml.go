@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/vahid-haghighat/oktaws/internal"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	samlFile   string
+	samlLogin  bool
+	samlOutput string
+	samlRaw    bool
+	samlXML    bool
+)
+
+var samlCmd = &cobra.Command{
+	Use:   "saml",
+	Short: "Work with SAML assertions",
+	Long:  `Inspect and troubleshoot the SAML assertions Okta issues for AWS`,
+}
+var samlDecodeCmd = &cobra.Command{
+	Use:     "decode",
+	Aliases: []string{"inspect"},
+	Short:   "Decode and inspect a SAMLResponse",
+	Long: `Read a SAMLResponse (base64 or a saved HTML page) from stdin, a file (--file) or a live login (--login) and print
+a readable report: issuer, subject, validity window, audience, attributes, roles, signature
+status and whether clock skew would make STS reject it.`,
+	RunE: runSAMLDecode,
+}
+
+func init() {
+	samlCmd.AddCommand(samlDecodeCmd)
+	samlDecodeCmd.Flags().StringVar(&samlFile, "file", "", "Read the SAMLResponse from a file instead of stdin")
+	samlDecodeCmd.Flags().BoolVar(&samlLogin, "login", false, "Log in to Okta and inspect the resulting SAMLResponse")
+	samlDecodeCmd.Flags().StringVarP(&samlOutput, "output", "o", "table", "Output format: table, json, or yaml")
+	samlDecodeCmd.Flags().BoolVar(&samlRaw, "raw", false, "Print the base64 SAMLResponse instead of a report")
+	samlDecodeCmd.Flags().BoolVar(&samlXML, "xml", false, "Print the decoded assertion XML instead of a report")
+}
+func runSAMLDecode(cmd *cobra.Command, args []string) error {
+	var cfg *internal.Config
+	var err error
+	if samlLogin {
+		cfg, err = loadAuthConfig()
+	} else {
+		cfg, err = internal.NewConfig()
+	}
+	if err != nil {
+		return err
+	}
+	auth := internal.NewAuthenticator(cfg)
+
+	var samlAssertion string
+	switch {
+	case samlLogin:
+		samlAssertion, err = auth.FetchSAMLAssertion()
+	case samlFile != "" && samlFile != "-":
+		f, openErr := os.Open(samlFile)
+		if openErr != nil {
+			return fmt.Errorf("failed to open SAML file: %w", openErr)
+		}
+		defer f.Close()
+		samlAssertion, err = internal.ReadSAMLResponse(f)
+	default:
+		samlAssertion, err = internal.ReadSAMLResponse(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	if samlRaw || samlXML {
+		return internal.PrintRawSAML(samlAssertion, samlXML)
+	}
+	return auth.InspectSAML(samlAssertion, samlOutput)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/vahid-haghighat/oktaws/internal"
+
+	"github.com/spf13/cobra"
+)
+
+var whoamiOutput string
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the assumed AWS session identity",
+	Long: `Authenticate, assume the selected role and print the resulting session metadata:
+assumed role, role session name, source identity and the session tags taken from SAML.
+Credentials are not written or printed.`,
+	RunE: runWhoAmI,
+}
+
+func init() {
+	whoamiCmd.Flags().StringVarP(&whoamiOutput, "output", "o", "table", "Output format: table, json, or yaml")
+}
+func runWhoAmI(cmd *cobra.Command, args []string) error {
+	cfg, err := loadAuthConfig()
+	if err != nil {
+		return err
+	}
+	auth := internal.NewAuthenticator(cfg)
+	return auth.WhoAmI(whoamiOutput)
+}
@@ -1,9 +1,8 @@
 package internal
 
 import (
-	"encoding/base64"
 	"encoding/json"
-	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,19 +11,29 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"gopkg.in/ini.v1"
 )
 
+const defaultSessionDuration = 3600
+
 type Authenticator struct {
-	config     *Config
-	httpClient *http.Client
+	config             *Config
+	httpClient         *http.Client
+	samlAttributes     samlSessionAttributes
+	session            *sessionMetadata
+	validatedAssertion string
+	assertionApps      map[string]string
+	samlRoleARN        string
+	out                io.Writer
 }
 
 func NewAuthenticator(cfg *Config) *Authenticator {
@@ -33,10 +42,41 @@ func NewAuthenticator(cfg *Config) *Authenticator {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		out: os.Stdout,
 	}
 }
 
 func (a *Authenticator) Authenticate() error {
+	switch authFlow := a.resolveAuthFlow(); authFlow {
+	case "oidc":
+		return a.AuthenticateWithOIDC()
+	case "saml-browser", "saml_browser":
+		return a.AuthenticateWithBrowser()
+	case "manual":
+		return a.AuthenticateWithSAMLInput()
+	case "sso":
+		return a.AuthenticateWithIdentityCenter()
+	default:
+		return fmt.Errorf("unknown authentication flow: %s (valid options: oidc, saml-browser, manual, sso, auto)", authFlow)
+	}
+}
+
+func (a *Authenticator) FetchSAMLAssertion() (string, error) {
+	switch authFlow := a.resolveAuthFlow(); authFlow {
+	case "oidc":
+		return a.samlAssertionFromOIDC()
+	case "saml-browser", "saml_browser":
+		return a.samlAssertionFromBrowser()
+	case "manual":
+		return a.samlAssertionFromInput()
+	case "sso":
+		return "", fmt.Errorf("the sso flow signs in to IAM Identity Center and has no SAML assertion; use --auth-flow oidc or saml-browser")
+	default:
+		return "", fmt.Errorf("unknown authentication flow: %s (valid options: oidc, saml-browser, manual, sso, auto)", authFlow)
+	}
+}
+
+func (a *Authenticator) resolveAuthFlow() string {
 	authFlow := a.config.AuthFlow
 	if authFlow == "auto" {
 		authFlow = a.detectAuthFlow()
@@ -46,88 +86,154 @@ func (a *Authenticator) Authenticate() error {
 		fmt.Fprintf(os.Stderr, "Using authentication flow: %s\n", authFlow)
 	}
 
-	switch authFlow {
-	case "oidc":
-		return a.AuthenticateWithOIDC()
-	case "saml-browser", "saml_browser":
-		return a.AuthenticateWithBrowser()
-	default:
-		return fmt.Errorf("unknown authentication flow: %s (valid options: oidc, saml-browser, auto)", authFlow)
-	}
+	return authFlow
 }
 
 func (a *Authenticator) detectAuthFlow() string {
-	if a.config.OIDCClientID != "" {
+	return a.config.detectAuthFlow()
+}
+
+func (c *Config) detectAuthFlow() string {
+	if c.SAMLFile != "" {
+		return "manual"
+	}
+	if c.SSOStartURL != "" {
+		return "sso"
+	}
+	if c.OIDCClientID != "" {
 		return "oidc"
 	}
-	if a.config.AWSAcctFedAppID != "" {
+	if c.AWSAcctFedAppID != "" {
 		return "saml-browser"
 	}
 	return "oidc"
 }
 
 func (a *Authenticator) AuthenticateWithOIDC() error {
-	deviceAuth, err := a.startDeviceAuthorization()
+	assertions, err := a.samlAssertionsFromOIDC()
 	if err != nil {
-		return fmt.Errorf("device authorization failed: %w", err)
+		return err
 	}
 
-	if err := a.displayAuthorizationURL(deviceAuth); err != nil {
+	samlAssertion, roleARN, principalARN, err := a.pickAppRole(assertions)
+	if err != nil {
 		return err
 	}
 
-	accessToken, err := a.pollForAccessToken(deviceAuth)
+	if a.config.Debug {
+		fmt.Fprintf(os.Stderr, "✓ Using role: %s\n", roleARN)
+	}
+
+	creds, err := a.assumeRole(samlAssertion, roleARN, principalARN)
 	if err != nil {
-		return fmt.Errorf("failed to obtain access token: %w", err)
+		return fmt.Errorf("failed to assume role: %w", err)
 	}
 
-	if a.config.Debug {
-		fmt.Fprintf(os.Stderr, "✓ Access token obtained\n")
+	if err := a.outputCredentials(creds); err != nil {
+		return err
 	}
 
-	if a.config.CacheAccessToken {
-		if err := a.cacheAccessToken(accessToken); err != nil && a.config.Debug {
-			fmt.Fprintf(os.Stderr, "Warning: failed to cache token: %v\n", err)
+	return a.writeReadOnlyProfile(samlAssertion, roleARN, principalARN, creds)
+}
+
+// samlAssertionFromOIDC returns a single assertion without prompting. When
+// several apps are in play it is the one granting aws_iam_role, if that is
+// set, or else the first app's.
+func (a *Authenticator) samlAssertionFromOIDC() (string, error) {
+	assertions, err := a.samlAssertionsFromOIDC()
+	if err != nil {
+		return "", err
+	}
+	if len(assertions) > 1 && a.config.AWSIAMRole != "" {
+		_, byRole, err := a.mergeAppRoles(assertions)
+		if err != nil {
+			return "", err
 		}
+		if samlAssertion, ok := byRole[a.config.AWSIAMRole]; ok {
+			return samlAssertion, nil
+		}
+	}
+	if len(assertions) > 1 {
+		fmt.Fprintf(os.Stderr, "Using the assertion from %s, the first of %d apps; set aws_iam_role or aws_acct_fed_app_id to pick another\n", assertions[0].app.name(), len(assertions))
 	}
+	return assertions[0].assertion, nil
+}
 
-	appID := a.config.AWSAcctFedAppID
-	if appID == "" {
-		appID, err = a.discoverAWSFedApp(accessToken)
+// fetchSAMLAssertionAndRole gets an assertion and the role to assume with it,
+// prompting for the role when there is a choice to make.
+func (a *Authenticator) fetchSAMLAssertionAndRole() (string, string, string, error) {
+	if a.resolveAuthFlow() == "oidc" {
+		assertions, err := a.samlAssertionsFromOIDC()
 		if err != nil {
-			return fmt.Errorf("failed to discover AWS Federation app: %w", err)
+			return "", "", "", err
 		}
+		return a.pickAppRole(assertions)
 	}
 
-	samlAssertion, err := a.getSAMLAssertion(accessToken, appID)
+	samlAssertion, err := a.FetchSAMLAssertion()
 	if err != nil {
-		return fmt.Errorf("failed to get SAML assertion: %w", err)
+		return "", "", "", err
 	}
+	roles, err := a.extractRolesFromSAML(samlAssertion)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to extract roles from SAML: %w", err)
+	}
+	roleARN, principalARN, err := a.selectRole(roles)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to select role: %w", err)
+	}
+	return samlAssertion, roleARN, principalARN, nil
+}
 
-	if a.config.Debug {
-		fmt.Fprintf(os.Stderr, "✓ SAML assertion obtained\n")
+func (a *Authenticator) samlAssertionsFromOIDC() ([]appAssertion, error) {
+	accessToken, err := a.oidcAccessToken()
+	if err != nil {
+		return nil, err
 	}
 
-	roles, err := a.extractRolesFromSAML(samlAssertion)
+	apps, err := a.selectAWSApps(accessToken)
 	if err != nil {
-		return fmt.Errorf("failed to extract roles from SAML: %w", err)
+		return nil, err
 	}
 
-	roleARN, principalARN, err := a.selectRole(roles)
+	assertions, err := a.fetchAppAssertions(accessToken, apps)
 	if err != nil {
-		return fmt.Errorf("failed to select role: %w", err)
+		return nil, err
 	}
 
 	if a.config.Debug {
-		fmt.Fprintf(os.Stderr, "✓ Using role: %s\n", roleARN)
+		fmt.Fprintf(os.Stderr, "✓ SAML assertion obtained from %d app(s)\n", len(assertions))
 	}
 
-	creds, err := a.assumeRoleWithSAML(samlAssertion, roleARN, principalARN)
+	return assertions, nil
+}
+
+func (a *Authenticator) oidcAccessToken() (string, error) {
+	deviceAuth, err := a.startDeviceAuthorization()
 	if err != nil {
-		return fmt.Errorf("failed to assume role: %w", err)
+		return "", fmt.Errorf("device authorization failed: %w", err)
+	}
+
+	if err := a.displayAuthorizationURL(deviceAuth); err != nil {
+		return "", err
+	}
+
+	accessToken, err := a.pollForAccessToken(deviceAuth)
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain access token: %w", err)
 	}
 
-	return a.outputCredentials(creds)
+	if a.config.Debug {
+		fmt.Fprintf(os.Stderr, "✓ Access token obtained\n")
+	}
+
+	if a.config.CacheAccessToken {
+		if err := a.cacheAccessToken(accessToken); err != nil && a.config.Debug {
+			fmt.Fprintf(os.Stderr, "Warning: failed to cache token: %v\n", err)
+		}
+	}
+
+	return accessToken, nil
 }
 
 type deviceAuthResponse struct {
@@ -184,13 +290,13 @@ func (a *Authenticator) startDeviceAuthorization() (*deviceAuthResponse, error)
 }
 
 func (a *Authenticator) displayAuthorizationURL(deviceAuth *deviceAuthResponse) error {
-	fmt.Println()
-	fmt.Println("To authenticate, visit:")
-	fmt.Println()
-	fmt.Printf("  %s\n", deviceAuth.VerificationURIComplete)
-	fmt.Println()
-	fmt.Printf("Or go to %s and enter code: %s\n", deviceAuth.VerificationURI, deviceAuth.UserCode)
-	fmt.Println()
+	fmt.Fprintln(a.out)
+	fmt.Fprintln(a.out, "To authenticate, visit:")
+	fmt.Fprintln(a.out)
+	fmt.Fprintf(a.out, "  %s\n", deviceAuth.VerificationURIComplete)
+	fmt.Fprintln(a.out)
+	fmt.Fprintf(a.out, "Or go to %s and enter code: %s\n", deviceAuth.VerificationURI, deviceAuth.UserCode)
+	fmt.Fprintln(a.out)
 
 	if a.config.OpenBrowser {
 		if err := a.openBrowser(deviceAuth.VerificationURIComplete); err != nil && a.config.Debug {
@@ -243,16 +349,16 @@ func (a *Authenticator) pollForAccessToken(deviceAuth *deviceAuthResponse) (stri
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	fmt.Print("Waiting for authentication")
+	fmt.Fprint(a.out, "Waiting for authentication")
 
 	for {
 		select {
 		case <-timeout:
-			fmt.Println()
+			fmt.Fprintln(a.out)
 			return "", fmt.Errorf("authentication timed out")
 
 		case <-ticker.C:
-			fmt.Print(".")
+			fmt.Fprint(a.out, ".")
 
 			data := url.Values{}
 			data.Set("client_id", a.config.OIDCClientID)
@@ -281,12 +387,12 @@ func (a *Authenticator) pollForAccessToken(deviceAuth *deviceAuthResponse) (stri
 			}
 
 			if tokenResp.AccessToken != "" {
-				fmt.Println(" ✓")
+				fmt.Fprintln(a.out, " ✓")
 				return tokenResp.AccessToken, nil
 			}
 
 			if tokenResp.Error != "" && tokenResp.Error != "authorization_pending" && tokenResp.Error != "slow_down" {
-				fmt.Println()
+				fmt.Fprintln(a.out)
 				return "", fmt.Errorf("authentication failed: %s - %s", tokenResp.Error, tokenResp.ErrorDesc)
 			}
 		}
@@ -325,12 +431,13 @@ type appLink struct {
 	Label         string `json:"label"`
 }
 
-func (a *Authenticator) discoverAWSFedApp(accessToken string) (string, error) {
+// listAWSApps returns the AWS Account Federation apps assigned to the user.
+func (a *Authenticator) listAWSApps(accessToken string) ([]AWSApp, error) {
 	appsURL := fmt.Sprintf("https://%s/api/v1/users/me/appLinks", a.config.OrgDomain)
 
 	req, err := http.NewRequest("GET", appsURL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+accessToken)
@@ -338,33 +445,34 @@ func (a *Authenticator) discoverAWSFedApp(accessToken string) (string, error) {
 
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to list apps: HTTP %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("failed to list apps: HTTP %d: %s", resp.StatusCode, string(body))
 	}
 
-	var apps []appLink
-	if err := json.Unmarshal(body, &apps); err != nil {
-		return "", fmt.Errorf("failed to parse apps response: %w", err)
+	var links []appLink
+	if err := json.Unmarshal(body, &links); err != nil {
+		return nil, fmt.Errorf("failed to parse apps response: %w", err)
 	}
 
-	for _, app := range apps {
-		if strings.Contains(strings.ToLower(app.Label), "aws") && strings.Contains(app.LinkURL, "/app/amazon_aws/") {
-			parts := strings.Split(app.LinkURL, "/")
-			for i, part := range parts {
-				if part == "amazon_aws" && i+1 < len(parts) {
-					return parts[i+1], nil
-				}
-			}
+	var apps []AWSApp
+	for _, link := range links {
+		if link.AppName != "amazon_aws" && !strings.Contains(link.LinkURL, "/amazon_aws/") {
+			continue
 		}
+		appID := link.AppInstanceID
+		if _, linkAppID, err := ParseEmbedLink(link.LinkURL); err == nil {
+			appID = linkAppID
+		}
+		apps = append(apps, AWSApp{ID: appID, Label: link.Label})
 	}
 
-	return "", fmt.Errorf("no AWS Federation app found in Okta apps list")
+	return apps, nil
 }
 
 func (a *Authenticator) getSAMLAssertion(accessToken, appID string) (string, error) {
@@ -398,80 +506,25 @@ func (a *Authenticator) getSAMLAssertion(accessToken, appID string) (string, err
 		return "", fmt.Errorf("SAML request failed with status %d", resp.StatusCode)
 	}
 
-	htmlContent := string(body)
-	start := strings.Index(htmlContent, `name="SAMLResponse" value="`)
-	if start == -1 {
-		return "", fmt.Errorf("SAMLResponse not found in HTML")
-	}
-
-	start += len(`name="SAMLResponse" value="`)
-	end := strings.Index(htmlContent[start:], `"`)
-	if end == -1 {
-		return "", fmt.Errorf("malformed SAMLResponse in HTML")
-	}
-
-	return htmlContent[start : start+end], nil
-}
-
-type samlResponse struct {
-	XMLName   xml.Name `xml:"Response"`
-	Assertion struct {
-		AttributeStatement struct {
-			Attributes []struct {
-				Name           string `xml:"Name,attr"`
-				AttributeValue []struct {
-					Value string `xml:",chardata"`
-				} `xml:"AttributeValue"`
-			} `xml:"Attribute"`
-		} `xml:"AttributeStatement"`
-	} `xml:"Assertion"`
-}
-
-type awsRole struct {
-	RoleARN      string
-	PrincipalARN string
-}
-
-func (a *Authenticator) extractRolesFromSAML(samlAssertion string) ([]awsRole, error) {
-	decodedSAML, err := base64.StdEncoding.DecodeString(samlAssertion)
+	form, err := parseSAMLForm(string(body))
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode SAML: %w", err)
-	}
-
-	var samlResp samlResponse
-	if err := xml.Unmarshal(decodedSAML, &samlResp); err != nil {
-		return nil, fmt.Errorf("failed to parse SAML XML: %w", err)
-	}
-
-	var roles []awsRole
-	for _, attr := range samlResp.Assertion.AttributeStatement.Attributes {
-		if attr.Name == "https://aws.amazon.com/SAML/Attributes/Role" {
-			for _, attrValue := range attr.AttributeValue {
-				parts := strings.Split(attrValue.Value, ",")
-				if len(parts) == 2 {
-					var role awsRole
-					if strings.Contains(parts[0], ":role/") {
-						role.RoleARN = parts[0]
-						role.PrincipalARN = parts[1]
-					} else {
-						role.RoleARN = parts[1]
-						role.PrincipalARN = parts[0]
-					}
-					roles = append(roles, role)
-				}
-			}
-		}
+		return "", err
 	}
 
-	if len(roles) == 0 {
-		return nil, fmt.Errorf("no IAM roles found in SAML assertion")
+	if a.config.Debug {
+		fmt.Fprintf(os.Stderr, "✓ SAML form posts to %s\n", form.Action)
 	}
 
-	return roles, nil
+	return form.SAMLResponse, nil
 }
 
 func (a *Authenticator) selectRole(roles []awsRole) (string, string, error) {
 	if a.config.AWSIAMRole != "" {
+		for _, role := range roles {
+			if role.RoleARN == a.config.AWSIAMRole {
+				return role.RoleARN, role.PrincipalARN, nil
+			}
+		}
 		for _, role := range roles {
 			if strings.Contains(role.RoleARN, a.config.AWSIAMRole) {
 				return role.RoleARN, role.PrincipalARN, nil
@@ -484,12 +537,22 @@ func (a *Authenticator) selectRole(roles []awsRole) (string, string, error) {
 		return roles[0].RoleARN, roles[0].PrincipalARN, nil
 	}
 
-	fmt.Println("\nAvailable AWS roles:")
+	// With stdin piped (or already read to EOF for the SAMLResponse) the
+	// prompt would read nothing and silently pick the first role.
+	if !isTerminal(os.Stdin) {
+		return "", "", fmt.Errorf("%d roles are available and stdin is not a terminal to choose one: pass --aws-iam-role", len(roles))
+	}
+
+	fmt.Fprintln(a.out, "\nAvailable AWS roles:")
 	for i, role := range roles {
-		fmt.Printf("  [%d] %s\n", i+1, role.RoleARN)
+		if role.App != "" {
+			fmt.Fprintf(a.out, "  [%d] %s (%s)\n", i+1, role.RoleARN, role.App)
+			continue
+		}
+		fmt.Fprintf(a.out, "  [%d] %s\n", i+1, role.RoleARN)
 	}
 
-	fmt.Print("\nSelect a role [1]: ")
+	fmt.Fprint(a.out, "\nSelect a role [1]: ")
 	var choice int
 	fmt.Scanln(&choice)
 
@@ -505,31 +568,110 @@ func (a *Authenticator) selectRole(roles []awsRole) (string, string, error) {
 	return selectedRole.RoleARN, selectedRole.PrincipalARN, nil
 }
 
-func (a *Authenticator) assumeRoleWithSAML(samlAssertion, roleARN, principalARN string) (*sts.Credentials, error) {
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region: aws.String(a.config.AWSRegion),
-	}))
+func (a *Authenticator) assumeRoleWithSAMLPolicy(samlAssertion, roleARN, principalARN string, policy sessionPolicy) (*sts.Credentials, error) {
+	if samlAssertion != a.validatedAssertion {
+		if err := a.validateSAMLAssertion(samlAssertion); err != nil {
+			return nil, fmt.Errorf("SAML assertion rejected: %w", err)
+		}
+		a.validatedAssertion = samlAssertion
+	}
+
+	sess, err := a.newSTSSession(roleARN, principalARN, nil)
+	if err != nil {
+		return nil, err
+	}
+	a.samlRoleARN = roleARN
 
 	stsClient := sts.New(sess)
 
+	duration := a.sessionDuration()
 	input := &sts.AssumeRoleWithSAMLInput{
 		RoleArn:         aws.String(roleARN),
 		PrincipalArn:    aws.String(principalARN),
 		SAMLAssertion:   aws.String(samlAssertion),
-		DurationSeconds: aws.Int64(int64(a.config.SessionDuration)),
+		DurationSeconds: aws.Int64(int64(duration)),
+		PolicyArns:      policyDescriptors(policy.arns),
+		Policy:          policy.document(),
 	}
 
 	result, err := stsClient.AssumeRoleWithSAML(input)
+	if isDurationExceededError(err) && duration > defaultSessionDuration {
+		result, err = a.retryWithRoleMaxDuration(stsClient, input, duration, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	a.session = newSessionMetadata(result, a.samlAttributes)
+	if a.config.Debug {
+		fmt.Fprintf(os.Stderr, "✓ Assumed role user: %s\n", a.session.AssumedRoleARN)
+		if a.session.SourceIdentity != "" {
+			fmt.Fprintf(os.Stderr, "✓ Source identity: %s\n", a.session.SourceIdentity)
+		}
+		for _, key := range sortedKeys(a.session.SessionTags) {
+			fmt.Fprintf(os.Stderr, "✓ Session tag: %s=%s\n", key, a.session.SessionTags[key])
+		}
+	}
+
 	return result.Credentials, nil
 }
 
+func (a *Authenticator) sessionDuration() int {
+	duration := defaultSessionDuration
+	if a.config.SessionDuration > 0 {
+		duration = a.config.SessionDuration
+	} else if a.samlAttributes.SessionDuration > 0 {
+		duration = a.samlAttributes.SessionDuration
+	}
+	if a.config.MaxSessionDuration > 0 && duration > a.config.MaxSessionDuration {
+		return a.config.MaxSessionDuration
+	}
+	return duration
+}
+
+// retryWithRoleMaxDuration assumes the role once more at the role's
+// MaxSessionDuration when STS names it in the rejection, and otherwise at the
+// one hour every role allows.
+func (a *Authenticator) retryWithRoleMaxDuration(stsClient *sts.STS, input *sts.AssumeRoleWithSAMLInput, rejected int, rejection error) (*sts.AssumeRoleWithSAMLOutput, error) {
+	duration := defaultSessionDuration
+	if roleMax := maxDurationFromError(rejection); roleMax >= defaultSessionDuration && roleMax < rejected {
+		duration = roleMax
+	}
+	input.DurationSeconds = aws.Int64(int64(duration))
+	result, err := stsClient.AssumeRoleWithSAML(input)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Warning: requested session duration of %ds exceeds the role's maximum; using %ds instead", rejected, duration)
+	return result, nil
+}
+
+var maxDurationPattern = regexp.MustCompile(`MaxSessionDuration\D*(\d+)`)
+
+func maxDurationFromError(err error) int {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return 0
+	}
+	m := maxDurationPattern.FindStringSubmatch(aerr.Message())
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+func isDurationExceededError(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	return aerr.Code() == "ValidationError" && strings.Contains(aerr.Message(), "DurationSeconds")
+}
+
 func (a *Authenticator) outputCredentials(creds *sts.Credentials) error {
 	if a.config.WriteAWSCredentials || a.config.Profile != "" {
-		if err := a.writeCredentialsFile(creds); err != nil {
+		if err := a.writeCredentialsFile(creds, a.config.Profile); err != nil {
 			return fmt.Errorf("failed to write credentials: %w", err)
 		}
 		log.Printf("Credentials written to profile: %s", a.config.Profile)
@@ -539,31 +681,24 @@ func (a *Authenticator) outputCredentials(creds *sts.Credentials) error {
 	switch a.config.Format {
 	case "json":
 		return a.outputJSON(creds)
-	case "env":
+	case "env", "env-var":
 		return a.outputEnv(creds)
 	default:
 		return a.outputJSON(creds)
 	}
 }
 
-func (a *Authenticator) writeCredentialsFile(creds *sts.Credentials) error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
+func (a *Authenticator) writeCredentialsFile(creds *sts.Credentials, profile string) error {
+	credsFile := awsCredentialsPath()
+	if err := os.MkdirAll(filepath.Dir(credsFile), 0700); err != nil {
 		return err
 	}
 
-	awsDir := filepath.Join(homeDir, ".aws")
-	if err := os.MkdirAll(awsDir, 0700); err != nil {
-		return err
-	}
-
-	credsFile := filepath.Join(awsDir, "credentials")
 	cfg, err := ini.Load(credsFile)
 	if err != nil {
 		cfg = ini.Empty()
 	}
 
-	profile := a.config.Profile
 	if profile == "" {
 		profile = "default"
 	}
@@ -576,6 +711,14 @@ func (a *Authenticator) writeCredentialsFile(creds *sts.Credentials) error {
 	section.Key("aws_access_key_id").SetValue(*creds.AccessKeyId)
 	section.Key("aws_secret_access_key").SetValue(*creds.SecretAccessKey)
 	section.Key("aws_session_token").SetValue(*creds.SessionToken)
+	if creds.Expiration != nil {
+		section.Key(credentialsExpiryKey).SetValue(creds.Expiration.UTC().Format(time.RFC3339))
+	}
+	if a.samlRoleARN != "" {
+		section.Key(credentialsRoleKey).SetValue(a.samlRoleARN)
+	} else {
+		section.DeleteKey(credentialsRoleKey)
+	}
 
 	return cfg.SaveTo(credsFile)
 }
@@ -588,14 +731,14 @@ func (a *Authenticator) outputJSON(creds *sts.Credentials) error {
 		"Expiration":      creds.Expiration.Format(time.RFC3339),
 	}
 
-	encoder := json.NewEncoder(os.Stdout)
+	encoder := json.NewEncoder(a.out)
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(output)
 }
 
 func (a *Authenticator) outputEnv(creds *sts.Credentials) error {
-	fmt.Printf("export AWS_ACCESS_KEY_ID=%s\n", *creds.AccessKeyId)
-	fmt.Printf("export AWS_SECRET_ACCESS_KEY=%s\n", *creds.SecretAccessKey)
-	fmt.Printf("export AWS_SESSION_TOKEN=%s\n", *creds.SessionToken)
+	fmt.Fprintf(a.out, "export AWS_ACCESS_KEY_ID=%s\n", *creds.AccessKeyId)
+	fmt.Fprintf(a.out, "export AWS_SECRET_ACCESS_KEY=%s\n", *creds.SecretAccessKey)
+	fmt.Fprintf(a.out, "export AWS_SESSION_TOKEN=%s\n", *creds.SessionToken)
 	return nil
 }
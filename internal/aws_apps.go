@@ -0,0 +1,283 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// AWSApp is an AWS Account Federation app assigned to the signed-in user.
+type AWSApp struct {
+	ID    string `json:"id" yaml:"id"`
+	Label string `json:"label" yaml:"label"`
+}
+
+func (app AWSApp) name() string {
+	if app.Label != "" {
+		return app.Label
+	}
+	return app.ID
+}
+
+// appAssertion is a SAML assertion together with the app that issued it,
+// whose metadata holds the certificate the assertion is checked against.
+type appAssertion struct {
+	app       AWSApp
+	assertion string
+}
+
+// selectAWSApps decides which apps to sign in to: the configured ones, or
+// every amazon_aws app the user has, narrowed down by a prompt when there
+// are several. The choice can then be saved to the config.
+func (a *Authenticator) selectAWSApps(accessToken string) ([]AWSApp, error) {
+	if a.config.AWSAcctFedAppID != "" {
+		return []AWSApp{{ID: a.config.AWSAcctFedAppID}}, nil
+	}
+	if len(a.config.AWSAcctFedAppIDs) > 0 && !a.config.AllApps {
+		var apps []AWSApp
+		for _, id := range a.config.AWSAcctFedAppIDs {
+			apps = append(apps, AWSApp{ID: id})
+		}
+		return apps, nil
+	}
+
+	apps, err := a.listAWSApps(accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover AWS Federation apps: %w", err)
+	}
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no AWS Federation app found in Okta apps list")
+	}
+	if a.config.AllApps {
+		return apps, nil
+	}
+
+	chosen := apps
+	if len(apps) > 1 {
+		fmt.Fprintln(a.out, "\nAvailable AWS apps:")
+		for i, app := range apps {
+			fmt.Fprintf(a.out, "  [%d] %s (%s)\n", i+1, app.Label, app.ID)
+		}
+		fmt.Fprint(a.out, "\nSelect apps, e.g. 2, 1,3 or all [1]: ")
+		var answer string
+		fmt.Scanln(&answer)
+		indexes, err := ParseSelection(answer, len(apps), answer != "")
+		if err != nil {
+			return nil, err
+		}
+		chosen = nil
+		for _, i := range indexes {
+			chosen = append(chosen, apps[i])
+		}
+	}
+	if err := a.rememberAWSApps(chosen); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save the app choice: %v\n", err)
+	}
+	return chosen, nil
+}
+
+// rememberAWSApps offers to save discovered app IDs to the selected context
+// (or the top level) so the next run skips discovery. Without a terminal to
+// ask on it only says what could be saved.
+func (a *Authenticator) rememberAWSApps(apps []AWSApp) error {
+	key, value := "aws_acct_fed_app_id", apps[0].ID
+	if len(apps) > 1 {
+		var ids []string
+		for _, app := range apps {
+			ids = append(ids, app.ID)
+		}
+		key, value = "aws_acct_fed_app_ids", strings.Join(ids, ",")
+	}
+	file, err := LoadOrNewConfigFile()
+	if err != nil {
+		return err
+	}
+	contextName, _ := selectedContext(file)
+	target := GetConfigPath()
+	if contextName != "" {
+		target += " (context " + contextName + ")"
+	}
+	if !isTerminal(os.Stdin) {
+		fmt.Fprintf(os.Stderr, "Set %s = %s in %s to skip app discovery next time\n", key, value, target)
+		return nil
+	}
+	fmt.Fprintf(a.out, "\nSave %s = %s to %s? [Y/n]: ", key, value, target)
+	var answer string
+	fmt.Scanln(&answer)
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "" && answer != "y" && answer != "yes" {
+		return nil
+	}
+
+	if contextName != "" {
+		err = file.SetContextValue(contextName, key, value)
+	} else {
+		err = file.SetValue(key, value)
+	}
+	if err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✓ Saved %s = %s to %s\n", key, value, target)
+	return nil
+}
+
+// fetchAppAssertions gets a SAML assertion from every app at once. An app
+// that fails is skipped with a warning as long as another one succeeds.
+func (a *Authenticator) fetchAppAssertions(accessToken string, apps []AWSApp) ([]appAssertion, error) {
+	results := make([]appAssertion, len(apps))
+	errs := make([]error, len(apps))
+	var wg sync.WaitGroup
+	for i, app := range apps {
+		wg.Add(1)
+		go func(i int, app AWSApp) {
+			defer wg.Done()
+			results[i].app = app
+			results[i].assertion, errs[i] = a.getSAMLAssertion(accessToken, app.ID)
+		}(i, app)
+	}
+	wg.Wait()
+
+	var assertions []appAssertion
+	for i, result := range results {
+		if errs[i] != nil {
+			if len(apps) == 1 {
+				return nil, fmt.Errorf("failed to get SAML assertion: %w", errs[i])
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", result.app.name(), errs[i])
+			continue
+		}
+		if a.assertionApps == nil {
+			a.assertionApps = map[string]string{}
+		}
+		a.assertionApps[result.assertion] = result.app.ID
+		assertions = append(assertions, result)
+	}
+	if len(assertions) == 0 {
+		return nil, fmt.Errorf("failed to get a SAML assertion from any of the %d apps", len(apps))
+	}
+	return assertions, nil
+}
+
+// mergeAppRoles lists the roles of every assertion in one list, labelled with
+// their app when there is more than one, and maps each role to its assertion.
+func (a *Authenticator) mergeAppRoles(assertions []appAssertion) ([]awsRole, map[string]string, error) {
+	var roles []awsRole
+	byRole := map[string]string{}
+	for _, aa := range assertions {
+		appRoles, err := a.extractRolesFromSAML(aa.assertion)
+		if err != nil {
+			if len(assertions) == 1 {
+				return nil, nil, fmt.Errorf("failed to extract roles from SAML: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", aa.app.name(), err)
+			continue
+		}
+		for _, role := range appRoles {
+			if _, seen := byRole[role.RoleARN]; seen {
+				continue
+			}
+			if len(assertions) > 1 {
+				role.App = aa.app.name()
+			}
+			byRole[role.RoleARN] = aa.assertion
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return nil, nil, fmt.Errorf("no IAM roles found in SAML assertion")
+	}
+	return roles, byRole, nil
+}
+
+// pickAppRole has the user choose a role across all assertions and returns
+// it with the assertion that grants it.
+func (a *Authenticator) pickAppRole(assertions []appAssertion) (string, string, string, error) {
+	roles, byRole, err := a.mergeAppRoles(assertions)
+	if err != nil {
+		return "", "", "", err
+	}
+	roleARN, principalARN, err := a.selectRole(roles)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to select role: %w", err)
+	}
+	samlAssertion := byRole[roleARN]
+	if len(assertions) > 1 {
+		// Session attributes such as SessionDuration must come from the chosen app.
+		if _, err := a.extractRolesFromSAML(samlAssertion); err != nil {
+			return "", "", "", err
+		}
+	}
+	return samlAssertion, roleARN, principalARN, nil
+}
+
+// ListApps signs in and prints the AWS Account Federation apps assigned to
+// the user, marking the ones the configuration uses.
+func (a *Authenticator) ListApps(format string) error {
+	if a.config.OIDCClientID == "" {
+		return fmt.Errorf("listing apps needs oidc_client_id: the list comes from the Okta API")
+	}
+	accessToken, err := a.oidcAccessToken()
+	if err != nil {
+		return err
+	}
+	apps, err := a.listAWSApps(accessToken)
+	if err != nil {
+		return err
+	}
+
+	return printStructured(format, apps, func() error {
+		configured := map[string]bool{a.config.AWSAcctFedAppID: true}
+		for _, id := range a.config.AWSAcctFedAppIDs {
+			configured[id] = true
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CONFIGURED\tAPP ID\tLABEL")
+		for _, app := range apps {
+			marker := ""
+			if configured[app.ID] {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", marker, app.ID, app.Label)
+		}
+		return w.Flush()
+	})
+}
+
+// ParseSelection turns "2", "1,3", "2-4" or "all" into zero-based indexes of
+// a list of n items. An empty answer means the first item, or every item when
+// many is set.
+func ParseSelection(answer string, n int, many bool) ([]int, error) {
+	if answer == "" && !many {
+		return []int{0}, nil
+	}
+	var indexes []int
+	if answer == "" || strings.EqualFold(answer, "all") {
+		for i := 0; i < n; i++ {
+			indexes = append(indexes, i)
+		}
+		return indexes, nil
+	}
+	for _, part := range strings.Split(answer, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			last = first
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(first))
+		to, err2 := strconv.Atoi(strings.TrimSpace(last))
+		if err1 != nil || err2 != nil || from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("invalid choice %q: pick numbers between 1 and %d", part, n)
+		}
+		for i := from; i <= to; i++ {
+			indexes = append(indexes, i-1)
+		}
+	}
+	if !many && len(indexes) != 1 {
+		return nil, fmt.Errorf("pick a single number between 1 and %d", n)
+	}
+	return indexes, nil
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// awsProfileKeys maps the okta_* keys users can put in an ~/.aws/config
+// profile to the oktaws configuration keys they set.
+var awsProfileKeys = map[string]string{
+	"okta_org_domain":       "org_domain",
+	"okta_app_id":           "aws_acct_fed_app_id",
+	"okta_role_arn":         "aws_iam_role",
+	"okta_session_duration": "session_duration",
+}
+
+func awsConfigPath() string {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".aws", "config")
+}
+
+// awsCredentialsPath is where oktaws writes profiles: $AWS_SHARED_CREDENTIALS_FILE
+// or ~/.aws/credentials.
+func awsCredentialsPath() string {
+	if path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".aws", "credentials")
+}
+
+// selectedAWSProfile picks the profile the same way the AWS CLI would, except
+// that an explicit --profile or OKTAWS_PROFILE wins, and so does a profile
+// declared in the project's .oktaws.yaml.
+func selectedAWSProfile(cfg *Config, sources ConfigSources) (string, string) {
+	if profile, source, ok := lookupOverride("profile"); ok {
+		return profile, source
+	}
+	if source := sources["profile"]; strings.HasPrefix(source, "project ") {
+		return cfg.Profile, source
+	}
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile, "env AWS_PROFILE"
+	}
+	if cfg.Profile != "" {
+		return cfg.Profile, sources["profile"]
+	}
+	return "default", "default"
+}
+
+func awsProfileSettings(profile string) (map[string]string, error) {
+	file, err := ini.Load(awsConfigPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", awsConfigPath(), err)
+	}
+	sectionName := "profile " + profile
+	if profile == "default" && !file.HasSection(sectionName) {
+		sectionName = "default"
+	}
+	section, err := file.GetSection(sectionName)
+	if err != nil {
+		return nil, nil
+	}
+	settings := map[string]string{}
+	for awsKey, key := range awsProfileKeys {
+		if section.HasKey(awsKey) {
+			settings[key] = section.Key(awsKey).String()
+		}
+	}
+	return settings, nil
+}
+
+func (c *Config) applyAWSProfile(sources ConfigSources) error {
+	profile, profileSource := selectedAWSProfile(c, sources)
+	c.Profile = profile
+	sources.set("profile", profileSource)
+	settings, err := awsProfileSettings(profile)
+	if err != nil {
+		return err
+	}
+	for key, value := range settings {
+		if err := c.SetValue(key, value); err != nil {
+			return fmt.Errorf("invalid setting in AWS profile %s: %w", profile, err)
+		}
+		sources.set(key, fmt.Sprintf("AWS profile %s (%s)", profile, awsConfigPath()))
+	}
+	return nil
+}
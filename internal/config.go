@@ -4,237 +4,263 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
-	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	AuthFlow            string `yaml:"auth_flow"`
-	OrgDomain           string `yaml:"org_domain"`
-	OIDCClientID        string `yaml:"oidc_client_id"`
-	AWSIAMRole          string `yaml:"aws_iam_role"`
-	AWSIAMIdP           string `yaml:"aws_iam_idp"`
-	AWSAcctFedAppID     string `yaml:"aws_acct_fed_app_id"`
-	Profile             string `yaml:"profile"`
-	SessionDuration     int    `yaml:"session_duration"`
-	Format              string `yaml:"format"`
-	AWSRegion           string `yaml:"aws_region"`
-	QRCode              bool   `yaml:"qr_code"`
-	OpenBrowser         bool   `yaml:"open_browser"`
-	OpenBrowserCommand  string `yaml:"open_browser_command"`
-	AllProfiles         bool   `yaml:"all_profiles"`
-	WriteAWSCredentials bool   `yaml:"write_aws_credentials"`
-	CacheAccessToken    bool   `yaml:"cache_access_token"`
-	Debug               bool   `yaml:"debug"`
-	DebugAPICalls       bool   `yaml:"debug_api_calls"`
+	AuthFlow            string         `yaml:"auth_flow"`
+	OrgDomain           string         `yaml:"org_domain"`
+	OrgDiscoveryDomain  string         `yaml:"org_discovery_domain"`
+	OIDCClientID        string         `yaml:"oidc_client_id"`
+	AWSIAMRole          string         `yaml:"aws_iam_role"`
+	AWSIAMIdP           string         `yaml:"aws_iam_idp"`
+	AWSAcctFedAppID     string         `yaml:"aws_acct_fed_app_id"`
+	AWSAcctFedAppIDs    []string       `yaml:"aws_acct_fed_app_ids,omitempty"`
+	AllApps             bool           `yaml:"all_apps"`
+	SSOStartURL         string         `yaml:"sso_start_url"`
+	SSORegion           string         `yaml:"sso_region"`
+	SSOAccountID        string         `yaml:"sso_account_id"`
+	SSORoleName         string         `yaml:"sso_role_name"`
+	Profile             string         `yaml:"profile"`
+	SessionDuration     int            `yaml:"session_duration"`
+	Format              string         `yaml:"format"`
+	AWSRegion           string         `yaml:"aws_region"`
+	STSEndpointMode     string         `yaml:"sts_endpoint_mode"`
+	UseFIPSEndpoint     bool           `yaml:"use_fips_endpoint"`
+	STSEndpoint         string         `yaml:"sts_endpoint"`
+	SAMLIdPCert         string         `yaml:"saml_idp_cert"`
+	SkipSAMLValidation  bool           `yaml:"skip_saml_validation"`
+	SAMLFile            string         `yaml:"-"`
+	MaxSessionDuration  int            `yaml:"-"`
+	RoleChain           []RoleChainHop `yaml:"role_chain,omitempty"`
+	SessionPolicyFile   string         `yaml:"session_policy_file"`
+	SessionPolicyARNs   []string       `yaml:"session_policy_arns,omitempty"`
+	ReadOnlyProfile     bool           `yaml:"read_only_profile"`
+	QRCode              bool           `yaml:"qr_code"`
+	OpenBrowser         bool           `yaml:"open_browser"`
+	OpenBrowserCommand  string         `yaml:"open_browser_command"`
+	AllProfiles         bool           `yaml:"all_profiles"`
+	WriteAWSCredentials bool           `yaml:"write_aws_credentials"`
+	CacheAccessToken    bool           `yaml:"cache_access_token"`
+	Debug               bool           `yaml:"debug"`
+	DebugAPICalls       bool           `yaml:"debug_api_calls"`
 }
 
 func NewConfig() (*Config, error) {
-	cfg, err := LoadConfigFromFile()
-	if err != nil {
-		cfg = &Config{}
-	}
-	cfg.MergeWithViper()
-	if cfg.SessionDuration == 0 {
-		cfg.SessionDuration = 3600
-	}
-	if cfg.Profile == "" {
-		cfg.Profile = "default"
-	}
-	if cfg.Format == "" {
-		cfg.Format = "env-var"
-	}
-	if cfg.AuthFlow == "" {
-		cfg.AuthFlow = "auto"
-	}
-	return cfg, nil
+	cfg, _, err := ResolveConfig()
+	return cfg, err
 }
-func (c *Config) MergeWithViper() {
-	if v := viper.GetString("auth-flow"); v != "" {
-		c.AuthFlow = v
-	}
-	if v := viper.GetString("org-domain"); v != "" {
-		c.OrgDomain = v
-	}
-	if v := viper.GetString("oidc-client-id"); v != "" {
-		c.OIDCClientID = v
-	}
-	if v := viper.GetString("aws-iam-role"); v != "" {
-		c.AWSIAMRole = v
-	}
-	if v := viper.GetString("aws-iam-idp"); v != "" {
-		c.AWSIAMIdP = v
-	}
-	if v := viper.GetString("aws-acct-fed-app-id"); v != "" {
-		c.AWSAcctFedAppID = v
-	}
-	if v := viper.GetString("profile"); v != "" {
-		c.Profile = v
+
+// GetConfigPath returns $XDG_CONFIG_HOME/oktaws/config.yaml, or
+// ~/.config/oktaws/config.yaml when XDG_CONFIG_HOME is unset. An existing
+// ~/.config/oktaws/config.yaml stays in use while the XDG location has none,
+// so setting XDG_CONFIG_HOME later does not strand the config, the unpacked
+// browser extension or the replay cache kept beside it.
+func GetConfigPath() string {
+	legacyPath := ""
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		legacyPath = filepath.Join(homeDir, ".config", "oktaws", "config.yaml")
 	}
-	if v := viper.GetString("format"); v != "" {
-		c.Format = v
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		return legacyPath
 	}
-	if v := viper.GetString("aws-region"); v != "" {
-		c.AWSRegion = v
+	path := filepath.Join(configHome, "oktaws", "config.yaml")
+	if legacyPath != "" && !fileExists(path) && fileExists(legacyPath) {
+		return legacyPath
 	}
-	if v := viper.GetString("open-browser-command"); v != "" {
-		c.OpenBrowserCommand = v
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+func LoadConfigFromFile() (*Config, error) {
+	file, err := LoadConfigFile()
+	if err != nil {
+		return nil, err
 	}
-	if durationStr := viper.GetString("aws-session-duration"); durationStr != "" {
-		if duration, err := strconv.Atoi(durationStr); err == nil {
-			c.SessionDuration = duration
+	contextName, _ := selectedContext(file)
+	return file.Resolve(contextName)
+}
+func ConfigKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := configKey(t.Field(i)); key != "" {
+			keys = append(keys, key)
 		}
 	}
-	if viper.IsSet("qr-code") {
-		c.QRCode = viper.GetBool("qr-code")
-	}
-	if viper.IsSet("open-browser") {
-		c.OpenBrowser = viper.GetBool("open-browser")
-	}
-	if viper.IsSet("all-profiles") {
-		c.AllProfiles = viper.GetBool("all-profiles")
-	}
-	if viper.IsSet("write-aws-credentials") {
-		c.WriteAWSCredentials = viper.GetBool("write-aws-credentials")
-	}
-	if viper.IsSet("cache-access-token") {
-		c.CacheAccessToken = viper.GetBool("cache-access-token")
-	}
-	if viper.IsSet("debug") {
-		c.Debug = viper.GetBool("debug")
-	}
-	if viper.IsSet("debug-api-calls") {
-		c.DebugAPICalls = viper.GetBool("debug-api-calls")
-	}
+	return keys
 }
-func GetConfigPath() string {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
+func configKey(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if key == "-" {
 		return ""
 	}
-	return filepath.Join(homeDir, ".config", "oktaws", "config.yaml")
+	return key
 }
-func LoadConfigFromFile() (*Config, error) {
-	configPath := GetConfigPath()
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, err
+func normalizeConfigKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+}
+
+// configField finds the Config field behind a yaml key.
+func (c *Config) configField(key string) (reflect.Value, error) {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if configKey(v.Type().Field(i)) == key {
+			return v.Field(i), nil
+		}
 	}
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+	return reflect.Value{}, fmt.Errorf("unknown configuration key: %s", key)
+}
+
+// configValueChecks holds the per-key rules beyond what the field type enforces.
+var configValueChecks = map[string]func(string) error{
+	"auth_flow":         oneOf("auth_flow", validAuthFlows),
+	"format":            oneOf("format", validFormats),
+	"sts_endpoint_mode": oneOf("sts_endpoint_mode", validSTSModes),
+	"sts_endpoint":      validateEndpointURL,
+	"session_duration": func(value string) error {
+		if d, _ := strconv.Atoi(value); d < minSessionDuration || d > maxSessionDuration {
+			return fmt.Errorf("invalid session_duration: must be between %d and %d seconds", minSessionDuration, maxSessionDuration)
+		}
+		return nil
+	},
+}
+
+func oneOf(key string, allowed []string) func(string) error {
+	return func(value string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid %s: must be one of %s", key, strings.Join(allowed, ", "))
 	}
-	return &cfg, nil
 }
-func (c *Config) SaveToFile() error {
-	configPath := GetConfigPath()
-	configDir := filepath.Dir(configPath)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return err
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "1", "on":
+		return true, nil
+	case "false", "no", "n", "0", "off":
+		return false, nil
 	}
-	data, err := yaml.Marshal(c)
+	return false, fmt.Errorf("must be true or false")
+}
+func (c *Config) SetValue(key, value string) error {
+	key = normalizeConfigKey(key)
+	field, err := c.configField(key)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath, data, 0600)
-}
-func (c *Config) SetValue(key, value string) error {
-	key = strings.ToLower(strings.ReplaceAll(key, "-", "_"))
-	switch key {
-	case "auth_flow":
-		if value != "auto" && value != "oidc" && value != "saml-browser" && value != "saml_browser" {
-			return fmt.Errorf("invalid auth_flow: must be 'auto', 'oidc', or 'saml-browser'")
+	if check, ok := configValueChecks[key]; ok {
+		if err := check(value); err != nil {
+			return err
 		}
-		c.AuthFlow = value
-	case "org_domain":
-		c.OrgDomain = value
-	case "oidc_client_id":
-		c.OIDCClientID = value
-	case "aws_iam_role":
-		c.AWSIAMRole = value
-	case "aws_iam_idp":
-		c.AWSIAMIdP = value
-	case "aws_acct_fed_app_id":
-		c.AWSAcctFedAppID = value
-	case "profile":
-		c.Profile = value
-	case "format":
-		c.Format = value
-	case "aws_region":
-		c.AWSRegion = value
-	case "open_browser_command":
-		c.OpenBrowserCommand = value
-	case "session_duration":
-		duration, err := strconv.Atoi(value)
+	}
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case int:
+		n, err := strconv.Atoi(value)
 		if err != nil {
-			return fmt.Errorf("invalid session_duration: must be a number")
+			return fmt.Errorf("invalid %s: must be a number", key)
 		}
-		c.SessionDuration = duration
-	case "qr_code":
-		c.QRCode = value == "true" || value == "yes" || value == "1"
-	case "open_browser":
-		c.OpenBrowser = value == "true" || value == "yes" || value == "1"
-	case "all_profiles":
-		c.AllProfiles = value == "true" || value == "yes" || value == "1"
-	case "write_aws_credentials":
-		c.WriteAWSCredentials = value == "true" || value == "yes" || value == "1"
-	case "cache_access_token":
-		c.CacheAccessToken = value == "true" || value == "yes" || value == "1"
-	case "debug":
-		c.Debug = value == "true" || value == "yes" || value == "1"
-	case "debug_api_calls":
-		c.DebugAPICalls = value == "true" || value == "yes" || value == "1"
+		field.SetInt(int64(n))
+	case bool:
+		b, err := parseConfigBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		field.SetBool(b)
+	case []string:
+		field.Set(reflect.ValueOf(splitList(value)))
+	case []RoleChainHop:
+		field.Set(reflect.ValueOf(roleChainFromARNs(splitList(value))))
 	default:
-		return fmt.Errorf("unknown configuration key: %s", key)
+		return fmt.Errorf("configuration key %s cannot be set from the command line", key)
 	}
 	return nil
 }
+func (c *Config) UnsetValue(key string) error {
+	field, err := c.configField(normalizeConfigKey(key))
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.Zero(field.Type()))
+	return nil
+}
 func (c *Config) GetValue(key string) (string, error) {
-	key = strings.ToLower(strings.ReplaceAll(key, "-", "_"))
-	switch key {
-	case "auth_flow":
-		return c.AuthFlow, nil
-	case "org_domain":
-		return c.OrgDomain, nil
-	case "oidc_client_id":
-		return c.OIDCClientID, nil
-	case "aws_iam_role":
-		return c.AWSIAMRole, nil
-	case "aws_iam_idp":
-		return c.AWSIAMIdP, nil
-	case "aws_acct_fed_app_id":
-		return c.AWSAcctFedAppID, nil
-	case "profile":
-		return c.Profile, nil
-	case "format":
-		return c.Format, nil
-	case "aws_region":
-		return c.AWSRegion, nil
-	case "open_browser_command":
-		return c.OpenBrowserCommand, nil
-	case "session_duration":
-		return strconv.Itoa(c.SessionDuration), nil
-	case "qr_code":
-		return strconv.FormatBool(c.QRCode), nil
-	case "open_browser":
-		return strconv.FormatBool(c.OpenBrowser), nil
-	case "all_profiles":
-		return strconv.FormatBool(c.AllProfiles), nil
-	case "write_aws_credentials":
-		return strconv.FormatBool(c.WriteAWSCredentials), nil
-	case "cache_access_token":
-		return strconv.FormatBool(c.CacheAccessToken), nil
-	case "debug":
-		return strconv.FormatBool(c.Debug), nil
-	case "debug_api_calls":
-		return strconv.FormatBool(c.DebugAPICalls), nil
+	field, err := c.configField(normalizeConfigKey(key))
+	if err != nil {
+		return "", err
+	}
+	switch value := field.Interface().(type) {
+	case string:
+		return value, nil
+	case int:
+		return strconv.Itoa(value), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case []string:
+		return strings.Join(value, ","), nil
+	case []RoleChainHop:
+		var arns []string
+		for _, hop := range value {
+			arns = append(arns, hop.RoleARN)
+		}
+		return strings.Join(arns, ","), nil
 	default:
-		return "", fmt.Errorf("unknown configuration key: %s", key)
+		return fmt.Sprint(value), nil
 	}
 }
-func init() {
-	viper.AutomaticEnv()
+func PrintConfig(cfg *Config, contextName, format string) error {
+	var v interface{} = cfg
+	if format == "json" {
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		var values map[string]interface{}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return err
+		}
+		v = values
+	}
+	return printStructured(format, v, func() error {
+		fmt.Println("Current Configuration:")
+		fmt.Println("=====================")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if contextName != "" {
+			fmt.Fprintf(w, "context:\t%s\n", contextName)
+		}
+		for _, key := range ConfigKeys() {
+			value, _ := cfg.GetValue(key)
+			fmt.Fprintf(w, "%s:\t%s\n", key, value)
+		}
+		return w.Flush()
+	})
+}
+func roleChainFromARNs(arns []string) []RoleChainHop {
+	var hops []RoleChainHop
+	for _, arn := range splitList(strings.Join(arns, ",")) {
+		hops = append(hops, RoleChainHop{RoleARN: arn})
+	}
+	return hops
+}
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
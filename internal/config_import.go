@@ -0,0 +1,412 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// ImportSources lists the tools `config import --from` understands.
+var ImportSources = []string{"gimme-aws-creds", "saml2aws", "okta-aws-cli"}
+
+// ImportedContext is one profile or account from another tool, translated
+// into an oktaws context.
+type ImportedContext struct {
+	Name     string
+	Inherits string
+	Config   Config
+}
+
+// ConfigImport is the result of reading another tool's configuration.
+// Untranslated lists every setting that has no oktaws equivalent or whose
+// value could not be used, so nothing is dropped silently.
+type ConfigImport struct {
+	Source       string
+	Path         string
+	Contexts     []ImportedContext
+	Untranslated []string
+}
+
+func (imp *ConfigImport) skip(context, key, value, reason string) {
+	imp.Untranslated = append(imp.Untranslated, fmt.Sprintf("%s: %s = %s (%s)", context, key, value, reason))
+}
+
+// set applies a value through Config.SetValue, reporting it instead of
+// failing the import when oktaws rejects it.
+func (imp *ConfigImport) set(ctx *ImportedContext, from, key, value string) {
+	if err := ctx.Config.SetValue(key, value); err != nil {
+		imp.skip(ctx.Name, from, value, err.Error())
+	}
+}
+
+// setOrgURL takes the org domain from a full Okta URL such as
+// https://acme.okta.com or https://acme.okta.com/home/amazon_aws/0oa.../272,
+// and the app ID too when the URL is an app embed link.
+func (imp *ConfigImport) setOrgURL(ctx *ImportedContext, from, value string) {
+	if orgDomain, appID, err := ParseEmbedLink(value); err == nil {
+		if ctx.Config.OrgDomain == "" {
+			ctx.Config.OrgDomain = orgDomain
+		}
+		ctx.Config.AWSAcctFedAppID = appID
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Hostname() == "" || !orgDomainPattern.MatchString(u.Hostname()) {
+		imp.skip(ctx.Name, from, value, "not an Okta URL")
+		return
+	}
+	if u.Path != "" && u.Path != "/" {
+		imp.skip(ctx.Name, from, value, "only the org domain was used; the path is not an app embed link")
+	}
+	ctx.Config.OrgDomain = u.Hostname()
+}
+
+// ImportConfig reads the configuration of another Okta/AWS login tool. path
+// overrides the tool's default config file location.
+func ImportConfig(source, path string) (*ConfigImport, error) {
+	switch source {
+	case "gimme-aws-creds":
+		return importGimmeAWSCreds(importPath(path, ".okta_aws_login_config"))
+	case "saml2aws":
+		return importSAML2AWS(importPath(path, ".saml2aws"))
+	case "okta-aws-cli":
+		return importOktaAWSCLI(path)
+	default:
+		return nil, fmt.Errorf("unknown import source: %s (valid options: %s)", source, strings.Join(ImportSources, ", "))
+	}
+}
+
+func importPath(path, name string) string {
+	if path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return name
+	}
+	return filepath.Join(homeDir, name)
+}
+
+func loadImportINI(path string) (*ini.File, error) {
+	file, err := ini.Load(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s not found; pass --file if it lives elsewhere", path)
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// gimmeIgnoredKeys are gimme-aws-creds settings with no oktaws counterpart
+// that are not worth reporting when left at the value gimme-aws-creds writes.
+// Empty settings are never reported.
+var gimmeIgnoredKeys = map[string]string{
+	"gimme_creds_server": "appurl",
+	"remember_device":    "False",
+	"resolve_aws_alias":  "False",
+	"include_path":       "False",
+	"enable_keychain":    "True",
+}
+
+func importGimmeAWSCreds(path string) (*ConfigImport, error) {
+	file, err := loadImportINI(path)
+	if err != nil {
+		return nil, err
+	}
+	imp := &ConfigImport{Source: "gimme-aws-creds", Path: path}
+	for _, section := range file.Sections() {
+		if len(section.Keys()) == 0 {
+			continue
+		}
+		ctx := ImportedContext{Name: strings.ToLower(section.Name())}
+		for _, key := range section.Keys() {
+			value := key.String()
+			switch key.Name() {
+			case "okta_org_url", "app_url":
+				imp.setOrgURL(&ctx, key.Name(), value)
+			case "client_id":
+				imp.set(&ctx, key.Name(), "oidc_client_id", value)
+			case "aws_rolename":
+				if value == "" {
+					continue
+				}
+				if value == "all" || strings.Contains(value, ",") {
+					imp.skip(ctx.Name, key.Name(), value, "oktaws assumes one role; pick one with --aws-iam-role or use 'oktaws roles'")
+					continue
+				}
+				imp.set(&ctx, key.Name(), "aws_iam_role", value)
+			case "aws_default_duration":
+				imp.set(&ctx, key.Name(), "session_duration", value)
+			case "cred_profile":
+				switch strings.ToLower(value) {
+				case "", "default":
+				case "role", "acc-role", "acc":
+					imp.skip(ctx.Name, key.Name(), value, "profile names derived from the role are not supported; set profile explicitly")
+				default:
+					ctx.Config.Profile = value
+				}
+			case "write_aws_creds":
+				imp.set(&ctx, key.Name(), "write_aws_credentials", value)
+			case "output_format":
+				switch value {
+				case "export":
+					ctx.Config.Format = "env-var"
+				case "json":
+					ctx.Config.Format = "json"
+				default:
+					imp.skip(ctx.Name, key.Name(), value, "unknown output format")
+				}
+			case "open_browser":
+				imp.set(&ctx, key.Name(), "open_browser", value)
+			case "inherits":
+				ctx.Inherits = strings.ToLower(value)
+			default:
+				if ignored, ok := gimmeIgnoredKeys[key.Name()]; value == "" || ok && strings.EqualFold(value, ignored) {
+					continue
+				}
+				imp.skip(ctx.Name, key.Name(), value, "no oktaws equivalent")
+			}
+		}
+		imp.Contexts = append(imp.Contexts, ctx)
+	}
+	return imp, nil
+}
+
+// saml2awsIgnoredKeys are saml2aws account settings with no oktaws
+// counterpart, with the value saml2aws writes by default.
+var saml2awsIgnoredKeys = map[string]string{
+	"mfa":                     "Auto",
+	"skip_verify":             "false",
+	"timeout":                 "0",
+	"aws_urn":                 "urn:amazon:webservices",
+	"disable_sessions":        "false",
+	"disable_remember_device": "false",
+}
+
+func importSAML2AWS(path string) (*ConfigImport, error) {
+	file, err := loadImportINI(path)
+	if err != nil {
+		return nil, err
+	}
+	imp := &ConfigImport{Source: "saml2aws", Path: path}
+	for _, section := range file.Sections() {
+		if len(section.Keys()) == 0 {
+			continue
+		}
+		ctx := ImportedContext{Name: strings.ToLower(section.Name())}
+		if provider := section.Key("provider").String(); !strings.EqualFold(provider, "Okta") {
+			imp.skip(ctx.Name, "provider", provider, "only Okta accounts can be imported; account skipped")
+			continue
+		}
+		for _, key := range section.Keys() {
+			value := key.String()
+			switch key.Name() {
+			case "provider", "name":
+			case "url":
+				imp.setOrgURL(&ctx, key.Name(), value)
+			case "role_arn":
+				if value != "" {
+					imp.set(&ctx, key.Name(), "aws_iam_role", value)
+				}
+			case "aws_session_duration":
+				if value != "" && value != "0" {
+					imp.set(&ctx, key.Name(), "session_duration", value)
+				}
+			case "aws_profile":
+				ctx.Config.Profile = value
+			case "region":
+				ctx.Config.AWSRegion = value
+			default:
+				if ignored, ok := saml2awsIgnoredKeys[key.Name()]; value == "" || ok && strings.EqualFold(value, ignored) {
+					continue
+				}
+				imp.skip(ctx.Name, key.Name(), value, "no oktaws equivalent")
+			}
+		}
+		imp.Contexts = append(imp.Contexts, ctx)
+	}
+	return imp, nil
+}
+
+// importOktaAWSCLI reads okta-aws-cli's OKTA_AWSCLI_* settings from a .env
+// file (./.env unless path is given) and the environment, plus the org URL
+// and aliases in ~/.okta/okta.yaml. A .yaml path is read as okta.yaml instead.
+func importOktaAWSCLI(path string) (*ConfigImport, error) {
+	envPath, oktaYAMLPath := ".env", importPath("", filepath.Join(".okta", "okta.yaml"))
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		oktaYAMLPath = path
+	} else if path != "" {
+		envPath = path
+	}
+	settings, err := readDotEnv(envPath, path != "" && envPath == path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range os.Environ() {
+		if name, value, ok := strings.Cut(entry, "="); ok && strings.HasPrefix(name, "OKTA_AWSCLI_") {
+			settings[name] = value
+		}
+	}
+	imp := &ConfigImport{Source: "okta-aws-cli", Path: envPath}
+	ctx := ImportedContext{Name: "okta-aws-cli"}
+
+	envKeys := map[string]string{}
+	for _, key := range ConfigKeys() {
+		envKeys["OKTA_AWSCLI_"+strings.ToUpper(key)] = key
+		if legacy, ok := legacyConfigEnv[key]; ok {
+			envKeys[legacy] = key
+		}
+	}
+	var names []string
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := settings[name]
+		if value == "" || name == "OKTA_AWSCLI_CONTEXT" {
+			continue
+		}
+		key, ok := envKeys[name]
+		switch {
+		case key == "format" && value == "aws-credentials":
+			ctx.Config.WriteAWSCredentials = true
+		case key == "format" && (value == "process-credentials" || value == "noop"):
+			imp.skip(ctx.Name, name, value, "output format not supported; use env-var or json")
+		case ok:
+			imp.set(&ctx, name, key, value)
+		default:
+			imp.skip(ctx.Name, name, value, "no oktaws equivalent")
+		}
+	}
+
+	if err := importOktaYAML(imp, &ctx, oktaYAMLPath, path == oktaYAMLPath); err != nil {
+		return nil, err
+	}
+	if reflect.ValueOf(ctx.Config).IsZero() && len(imp.Untranslated) == 0 {
+		return nil, fmt.Errorf("no okta-aws-cli settings found in the environment, %s or %s", envPath, oktaYAMLPath)
+	}
+	imp.Contexts = append(imp.Contexts, ctx)
+	return imp, nil
+}
+
+func readDotEnv(path string, required bool) (map[string]string, error) {
+	settings := map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return settings, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		settings[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return settings, scanner.Err()
+}
+
+func importOktaYAML(imp *ConfigImport, ctx *ImportedContext, path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var oktaYAML struct {
+		Okta struct {
+			Client struct {
+				OrgURL string `yaml:"orgUrl"`
+			} `yaml:"client"`
+		} `yaml:"okta"`
+		AWSCLI struct {
+			IdPs  map[string]string `yaml:"idps"`
+			Roles map[string]string `yaml:"roles"`
+		} `yaml:"awscli"`
+	}
+	if err := yaml.Unmarshal(data, &oktaYAML); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if orgURL := oktaYAML.Okta.Client.OrgURL; orgURL != "" && ctx.Config.OrgDomain == "" {
+		imp.setOrgURL(ctx, "okta.client.orgUrl", orgURL)
+	}
+	for _, aliases := range []struct {
+		key    string
+		values map[string]string
+	}{{"awscli.idps", oktaYAML.AWSCLI.IdPs}, {"awscli.roles", oktaYAML.AWSCLI.Roles}} {
+		var arns []string
+		for arn := range aliases.values {
+			arns = append(arns, arn)
+		}
+		sort.Strings(arns)
+		for _, arn := range arns {
+			imp.skip(ctx.Name, aliases.key+"."+arn, aliases.values[arn], "display aliases are not supported")
+		}
+	}
+	return nil
+}
+
+// ApplyImport writes the imported contexts into the file. Existing contexts
+// with the same name are only replaced when overwrite is set.
+func (f *ConfigFile) ApplyImport(imp *ConfigImport, overwrite bool) error {
+	if !overwrite {
+		var clashes []string
+		for _, ctx := range imp.Contexts {
+			if f.HasContext(ctx.Name) {
+				clashes = append(clashes, ctx.Name)
+			}
+		}
+		if len(clashes) > 0 {
+			return fmt.Errorf("contexts already exist: %s (use --overwrite to replace them)", strings.Join(clashes, ", "))
+		}
+	}
+	for _, ctx := range imp.Contexts {
+		if overwrite && f.HasContext(ctx.Name) {
+			delete(f.Contexts, ctx.Name)
+		}
+		if err := f.SetContext(ctx.Name, &ctx.Config); err != nil {
+			return err
+		}
+	}
+	for _, ctx := range imp.Contexts {
+		if ctx.Inherits == "" {
+			continue
+		}
+		if !f.HasContext(ctx.Inherits) {
+			imp.skip(ctx.Name, "inherits", ctx.Inherits, "no such profile")
+			continue
+		}
+		if err := f.SetContextValue(ctx.Name, contextInheritsKey, ctx.Inherits); err != nil {
+			imp.skip(ctx.Name, "inherits", ctx.Inherits, err.Error())
+		}
+	}
+	if f.CurrentContext == "" && len(imp.Contexts) > 0 {
+		f.CurrentContext = imp.Contexts[0].Name
+		for _, ctx := range imp.Contexts {
+			if ctx.Name == "default" {
+				f.CurrentContext = ctx.Name
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion is the config.yaml layout this build reads and writes.
+// Files without a version field are the original flat layout, version 1.
+const CurrentConfigVersion = 2
+
+type configMigration struct {
+	description string
+	migrate     func(root *yaml.Node) error
+}
+
+// configMigrations[i] upgrades a version i+1 file to version i+2. Append to
+// this list, and bump CurrentConfigVersion, for every layout change.
+var configMigrations = []configMigration{
+	{
+		description: "drop empty keys and the default session_duration written by older versions and normalise auth_flow and format spellings",
+		migrate:     migrateConfigV1,
+	},
+}
+
+// migrateConfigV1 tidies a file written before versioning. Older releases
+// wrote every key at the top level, empty or not; those empty keys are
+// dropped, as Marshal would drop them on the next save. Inside contexts an
+// empty value overrides an inherited one, so there only spellings change.
+//
+// Older `config init` also wrote session_duration: 3600 whenever the prompt
+// was left empty. That pinned every session to an hour and hid the SAML
+// SessionDuration attribute, so a top-level 3600 is dropped too; without the
+// attribute oktaws still uses one hour.
+//
+// The file is rewritten, once, mainly to stamp it with a version: migrations
+// for later layout changes need to know where a file starts from, and a
+// file without a version always reads as version 1.
+func migrateConfigV1(root *yaml.Node) error {
+	var kept []*yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Value == "contexts" && value.Kind == yaml.MappingNode {
+			for j := 1; j < len(value.Content); j += 2 {
+				normalizeV1Spellings(value.Content[j])
+			}
+		} else if isZeroNode(value) || isV1DefaultSessionDuration(key, value) {
+			continue
+		}
+		kept = append(kept, key, value)
+	}
+	root.Content = kept
+	normalizeV1Spellings(root)
+	return nil
+}
+
+func isV1DefaultSessionDuration(key, value *yaml.Node) bool {
+	return key.Value == "session_duration" && value.Kind == yaml.ScalarNode && value.Value == fmt.Sprint(defaultSessionDuration)
+}
+
+func normalizeV1Spellings(settings *yaml.Node) {
+	if settings.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(settings.Content); i += 2 {
+		key, value := settings.Content[i], settings.Content[i+1]
+		switch {
+		case key.Value == "auth_flow" && value.Value == "saml_browser":
+			value.Value = "saml-browser"
+		case key.Value == "format" && value.Value == "env":
+			value.Value = "env-var"
+		}
+	}
+}
+
+func configVersion(root *yaml.Node) (int, error) {
+	node := mappingNode(root, "version")
+	if node == nil {
+		return 1, nil
+	}
+	var version int
+	if err := node.Decode(&version); err != nil || version < 1 {
+		return 0, fmt.Errorf("line %d: version must be a positive number", node.Line)
+	}
+	return version, nil
+}
+
+// migrateConfigData upgrades a config document to CurrentConfigVersion,
+// returning the new document and a description of each step applied.
+func migrateConfigData(data []byte) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return data, nil, nil
+	}
+	root := doc.Content[0]
+	version, err := configVersion(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version > CurrentConfigVersion {
+		return nil, nil, fmt.Errorf("config file version %d is newer than this oktaws supports (%d); upgrade oktaws", version, CurrentConfigVersion)
+	}
+	if version == CurrentConfigVersion {
+		return data, nil, nil
+	}
+
+	var steps []string
+	for ; version < CurrentConfigVersion; version++ {
+		migration := configMigrations[version-1]
+		if err := migration.migrate(root); err != nil {
+			return nil, nil, fmt.Errorf("migrating config from version %d to %d: %w", version, version+1, err)
+		}
+		steps = append(steps, fmt.Sprintf("v%d -> v%d: %s", version, version+1, migration.description))
+	}
+	removeMappingValue(root, "version")
+	root.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprint(CurrentConfigVersion)},
+	}, root.Content...)
+
+	migrated, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrated, steps, nil
+}
+
+// migrateConfigFile upgrades the file at path in place, keeping a copy of the
+// original next to it.
+func migrateConfigFile(path string, data []byte) ([]byte, error) {
+	migrated, steps, err := migrateConfigData(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(steps) == 0 {
+		return data, nil
+	}
+	backupPath := fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(backupPath, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to back up %s before migrating it: %w", path, err)
+	}
+	if err := os.WriteFile(path, migrated, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write migrated config: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Migrated %s to config version %d (backup: %s)\n", path, CurrentConfigVersion, backupPath)
+	return migrated, nil
+}
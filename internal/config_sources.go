@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const configEnvPrefix = "OKTAWS"
+
+// configFlagNames lists the config keys whose command-line flag is not just
+// the key with dashes.
+var configFlagNames = map[string]string{
+	"session_duration": "aws-session-duration",
+}
+
+// legacyConfigEnv keeps the okta-aws-cli style variables working alongside
+// the OKTAWS_<KEY> names.
+var legacyConfigEnv = map[string]string{
+	"org_domain":           "OKTA_AWSCLI_ORG_DOMAIN",
+	"oidc_client_id":       "OKTA_AWSCLI_OIDC_CLIENT_ID",
+	"aws_iam_role":         "OKTA_AWSCLI_IAM_ROLE",
+	"aws_iam_idp":          "OKTA_AWSCLI_IAM_IDP",
+	"aws_acct_fed_app_id":  "OKTA_AWSCLI_AWS_ACCOUNT_FEDERATION_APP_ID",
+	"profile":              "OKTA_AWSCLI_PROFILE",
+	"session_duration":     "OKTA_AWSCLI_SESSION_DURATION",
+	"format":               "OKTA_AWSCLI_FORMAT",
+	"aws_region":           "OKTA_AWSCLI_AWS_REGION",
+	"sts_endpoint_mode":    "OKTA_AWSCLI_STS_ENDPOINT_MODE",
+	"sts_endpoint":         "OKTA_AWSCLI_STS_ENDPOINT",
+	"saml_idp_cert":        "OKTA_AWSCLI_SAML_IDP_CERT",
+	"session_policy_file":  "OKTA_AWSCLI_SESSION_POLICY_FILE",
+	"open_browser_command": "OKTA_AWSCLI_BROWSER_COMMAND",
+}
+
+var commandFlags *pflag.FlagSet
+
+// BindFlags registers the command-line flags that override config keys.
+func BindFlags(flags *pflag.FlagSet) {
+	commandFlags = flags
+}
+
+// ConfigSources records where each effective config value came from.
+type ConfigSources map[string]string
+
+func (s ConfigSources) set(key, source string) {
+	if s != nil {
+		s[key] = source
+	}
+}
+
+func configFlagName(key string) string {
+	if name, ok := configFlagNames[key]; ok {
+		return name
+	}
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+func configEnvNames(key string) []string {
+	names := []string{configEnvPrefix + "_" + strings.ToUpper(key)}
+	if legacy, ok := legacyConfigEnv[key]; ok {
+		names = append(names, legacy)
+	}
+	return names
+}
+
+// lookupOverride returns the flag or environment value for a config key, in
+// that order of precedence.
+func lookupOverride(key string) (string, string, bool) {
+	if value, source, ok := lookupFlag(key); ok {
+		return value, source, true
+	}
+	for _, env := range configEnvNames(key) {
+		if value := os.Getenv(env); value != "" {
+			return value, "env " + env, true
+		}
+	}
+	return "", "", false
+}
+
+func lookupFlag(key string) (string, string, bool) {
+	if commandFlags == nil {
+		return "", "", false
+	}
+	name := configFlagName(key)
+	flag := commandFlags.Lookup(name)
+	if flag == nil || !flag.Changed {
+		return "", "", false
+	}
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		return strings.Join(slice.GetSlice(), ","), "flag --" + name, true
+	}
+	return flag.Value.String(), "flag --" + name, true
+}
+
+func (c *Config) applyOverrides(sources ConfigSources) error {
+	if v := viper.GetString("saml-file"); v != "" {
+		c.SAMLFile = v
+	}
+	return c.applyValues(lookupOverride, sources)
+}
+
+// ApplyFlags sets every config key given on the command line, ignoring the
+// environment.
+func (c *Config) ApplyFlags() error {
+	return c.applyValues(lookupFlag, nil)
+}
+
+func (c *Config) applyValues(lookup func(string) (string, string, bool), sources ConfigSources) error {
+	for _, key := range ConfigKeys() {
+		value, source, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := c.SetValue(key, value); err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		sources.set(key, source)
+	}
+	return nil
+}
+
+func selectedContext(file *ConfigFile) (string, string) {
+	if name := viper.GetString("context"); name != "" {
+		return name, "--context / OKTAWS_CONTEXT"
+	}
+	if project, err := LoadProjectConfig(); err == nil && project != nil && project.Context != "" {
+		return project.Context, "project " + project.Path
+	}
+	if file.CurrentContext != "" {
+		return file.CurrentContext, "current_context"
+	}
+	return "", ""
+}
+
+// ResolveConfig builds the effective configuration and records the source of
+// every value: built-in default, system config, config file, context, project
+// file, AWS profile, env or flag. Keys the system config locks are enforced last.
+func ResolveConfig() (*Config, ConfigSources, error) {
+	sources := ConfigSources{}
+	file, err := LoadOrNewConfigFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	system, err := LoadSystemConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	contextName, _ := selectedContext(file)
+	cfg, err := file.resolve(contextName, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	if system != nil {
+		system.fill(cfg, sources)
+	}
+	project, err := LoadProjectConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	if project != nil {
+		if err := project.apply(cfg, sources); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.applyAWSProfile(sources); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.applyOverrides(sources); err != nil {
+		return nil, nil, err
+	}
+	defaults := map[string]*string{"profile": &cfg.Profile, "format": &cfg.Format, "auth_flow": &cfg.AuthFlow}
+	defaultValues := map[string]string{"profile": "default", "format": "env-var", "auth_flow": "auto"}
+	for key, field := range defaults {
+		if *field == "" {
+			*field = defaultValues[key]
+			sources.set(key, "default")
+		}
+	}
+	if system != nil {
+		if err := system.enforce(cfg, sources); err != nil {
+			return nil, nil, err
+		}
+	}
+	return cfg, sources, nil
+}
+
+type configExplanation struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+func ExplainConfig(format string) error {
+	cfg, sources, err := ResolveConfig()
+	if err != nil {
+		return err
+	}
+	var explanations []configExplanation
+	for _, key := range ConfigKeys() {
+		value, _ := cfg.GetValue(key)
+		source := sources[key]
+		if source == "" {
+			source = "default"
+		}
+		explanations = append(explanations, configExplanation{Key: key, Value: value, Source: source})
+	}
+
+	return printStructured(format, explanations, func() error {
+		file, err := LoadOrNewConfigFile()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(SystemConfigPath()); err == nil {
+			fmt.Printf("System:      %s\n", SystemConfigPath())
+		}
+		fmt.Printf("Config file: %s\n", GetConfigPath())
+		if path := FindProjectConfig(); path != "" {
+			fmt.Printf("Project:     %s\n", path)
+		}
+		if name, from := selectedContext(file); name != "" {
+			fmt.Printf("Context:     %s (from %s)\n", name, from)
+		}
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		for _, e := range explanations {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", e.Key, e.Value, e.Source)
+		}
+		return w.Flush()
+	})
+}
+
+func init() {
+	viper.SetEnvPrefix(configEnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viper.AutomaticEnv()
+}
@@ -0,0 +1,466 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	minSessionDuration = 900
+	maxSessionDuration = 43200
+)
+
+var (
+	validAuthFlows     = []string{"auto", "oidc", "saml-browser", "saml_browser", "manual", "sso"}
+	validFormats       = []string{"env-var", "env", "json"}
+	validSTSModes      = []string{"global", "regional"}
+	roleARNPattern     = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]+$`)
+	idpARNPattern      = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:saml-provider/[\w.-]+$`)
+	policyARNPattern   = regexp.MustCompile(`^arn:aws[a-z-]*:iam::(aws|\d{12}):policy/[\w+=,.@/-]+$`)
+	mfaSerialPattern   = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:mfa/[\w+=,.@/-]+$`)
+	oktaAppIDPattern   = regexp.MustCompile(`^(0oa|exk)[0-9A-Za-z]{17}$`)
+	accountIDPattern   = regexp.MustCompile(`^\d{12}$`)
+	regionPattern      = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d$`)
+	orgDomainPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)+$`)
+	yamlErrorLineRegex = regexp.MustCompile(`line (\d+)`)
+)
+
+// ConfigProblem is a single finding from ValidateConfig, located by the line
+// of the offending key in the config file.
+type ConfigProblem struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	location := ""
+	if p.Line > 0 {
+		location = fmt.Sprintf("line %d: ", p.Line)
+	}
+	if p.Path == "" {
+		return location + p.Message
+	}
+	return fmt.Sprintf("%s%s: %s", location, p.Path, p.Message)
+}
+
+type configValidator struct {
+	problems []ConfigProblem
+}
+
+func (v *configValidator) report(node *yaml.Node, path, format string, args ...interface{}) {
+	line := 0
+	if node != nil {
+		line = node.Line
+	}
+	v.problems = append(v.problems, ConfigProblem{Line: line, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// configFields maps each yaml key of Config to its field type.
+func configFields() map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := configKey(t.Field(i)); key != "" {
+			fields[key] = t.Field(i).Type
+		}
+	}
+	return fields
+}
+
+// ValidateConfigFile checks the file as written and dry-runs any pending
+// migration, returning the migration steps that loading it would apply.
+func ValidateConfigFile(path string) ([]ConfigProblem, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if path == SystemConfigPath() {
+		return ValidateSystemConfig(data), nil, nil
+	}
+	problems := ValidateConfig(data)
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil {
+		return problems, nil, nil
+	}
+	migrated, steps, err := migrateConfigData(data)
+	if err != nil {
+		return append(problems, ConfigProblem{Message: err.Error()}), nil, nil
+	}
+	if len(steps) > 0 {
+		reported := map[string]bool{}
+		for _, problem := range problems {
+			reported[problem.Path+"\x00"+problem.Message] = true
+		}
+		for _, problem := range ValidateConfig(migrated) {
+			if reported[problem.Path+"\x00"+problem.Message] {
+				continue
+			}
+			problem.Line = 0
+			problem.Message = "after migration: " + problem.Message
+			problems = append(problems, problem)
+		}
+	}
+	return problems, steps, nil
+}
+
+func ValidateConfig(data []byte) []ConfigProblem {
+	v := &configValidator{}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		problem := ConfigProblem{Message: err.Error()}
+		if m := yamlErrorLineRegex.FindStringSubmatchIndex(err.Error()); m != nil {
+			fmt.Sscanf(err.Error()[m[2]:m[3]], "%d", &problem.Line)
+			problem.Message = "invalid YAML" + err.Error()[m[1]:]
+		}
+		return []ConfigProblem{problem}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.report(root, "", "config file must be a mapping of keys to values")
+		return v.problems
+	}
+
+	var currentContext *yaml.Node
+	var contexts *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "current_context":
+			currentContext = value
+		case "contexts":
+			contexts = value
+		case "version":
+			if _, err := configVersion(root); err != nil {
+				v.report(value, "version", "must be a positive number")
+			}
+		default:
+			v.checkKey(key, value, "")
+		}
+	}
+
+	names := map[string]*yaml.Node{}
+	if contexts != nil {
+		if contexts.Kind != yaml.MappingNode {
+			v.report(contexts, "contexts", "must be a mapping of context names to settings")
+		} else {
+			for i := 0; i+1 < len(contexts.Content); i += 2 {
+				name, settings := contexts.Content[i], contexts.Content[i+1]
+				names[name.Value] = settings
+				v.checkContext(name.Value, settings)
+			}
+			v.checkInheritance(names)
+		}
+	}
+	if currentContext != nil && currentContext.Value != "" {
+		if _, ok := names[currentContext.Value]; !ok {
+			v.report(currentContext, "current_context", "unknown context %q", currentContext.Value)
+		}
+	}
+
+	sort.SliceStable(v.problems, func(i, j int) bool { return v.problems[i].Line < v.problems[j].Line })
+	return v.problems
+}
+
+func (v *configValidator) checkContext(name string, settings *yaml.Node) {
+	path := "contexts." + name
+	if settings.Kind != yaml.MappingNode {
+		v.report(settings, path, "context settings must be a mapping")
+		return
+	}
+	for i := 0; i+1 < len(settings.Content); i += 2 {
+		key, value := settings.Content[i], settings.Content[i+1]
+		if key.Value == contextInheritsKey {
+			if value.Kind != yaml.ScalarNode {
+				v.report(value, path+"."+key.Value, "must be a context name")
+			}
+			continue
+		}
+		v.checkKey(key, value, path+".")
+	}
+}
+
+func (v *configValidator) checkInheritance(contexts map[string]*yaml.Node) {
+	file := &ConfigFile{Contexts: map[string]yaml.Node{}}
+	for name, settings := range contexts {
+		file.Contexts[name] = *settings
+	}
+	reported := map[string]bool{}
+	for _, name := range file.ContextNames() {
+		if _, err := file.contextChain(name); err != nil && !reported[err.Error()] {
+			reported[err.Error()] = true
+			settings := contexts[name]
+			v.report(mappingNode(settings, contextInheritsKey), "contexts."+name, "%v", err)
+		}
+	}
+}
+
+func (v *configValidator) checkKey(key, value *yaml.Node, prefix string) {
+	path := prefix + key.Value
+	fields := configFields()
+	fieldType, ok := fields[key.Value]
+	if !ok {
+		if suggestion := closestKey(key.Value, fields); suggestion != "" {
+			v.report(key, path, "unknown key (did you mean %q?)", suggestion)
+		} else {
+			v.report(key, path, "unknown key")
+		}
+		return
+	}
+	target := reflect.New(fieldType)
+	if err := value.Decode(target.Interface()); err != nil {
+		v.report(value, path, "expected %s", describeType(fieldType))
+		return
+	}
+	if key.Value == "role_chain" {
+		v.checkRoleChain(value, path)
+		return
+	}
+	v.checkValue(value, path, key.Value, target.Elem().Interface())
+}
+
+func (v *configValidator) checkValue(node *yaml.Node, path, key string, value interface{}) {
+	switch key {
+	case "auth_flow":
+		v.checkOneOf(node, path, value.(string), validAuthFlows)
+	case "format":
+		v.checkOneOf(node, path, value.(string), validFormats)
+	case "sts_endpoint_mode":
+		v.checkOneOf(node, path, value.(string), validSTSModes)
+	case "session_duration":
+		if d := value.(int); d != 0 && (d < minSessionDuration || d > maxSessionDuration) {
+			v.report(node, path, "%d is outside the %d-%d seconds AWS allows", d, minSessionDuration, maxSessionDuration)
+		}
+	case "org_domain", "org_discovery_domain":
+		if s := value.(string); s != "" && !orgDomainPattern.MatchString(s) {
+			v.report(node, path, "%q is not a host name (drop any https:// or path)", s)
+		}
+	case "aws_acct_fed_app_id":
+		if s := value.(string); s != "" && !oktaAppIDPattern.MatchString(s) {
+			v.report(node, path, "%q is not an Okta app ID (20 characters starting with 0oa or exk)", s)
+		}
+	case "aws_acct_fed_app_ids":
+		for i, id := range value.([]string) {
+			if !oktaAppIDPattern.MatchString(id) {
+				v.report(node.Content[i], path, "%q is not an Okta app ID (20 characters starting with 0oa or exk)", id)
+			}
+		}
+	case "sso_start_url":
+		if s := value.(string); s != "" && !strings.HasPrefix(s, "https://") {
+			v.report(node, path, "%q is not an https:// URL (e.g. https://my-org.awsapps.com/start)", s)
+		}
+	case "sso_region":
+		if s := value.(string); s != "" && !regionPattern.MatchString(s) {
+			v.report(node, path, "%q is not an AWS region (e.g. us-east-1)", s)
+		}
+	case "sso_account_id":
+		if s := value.(string); s != "" && !accountIDPattern.MatchString(s) {
+			v.report(node, path, "%q is not a 12-digit AWS account ID", s)
+		}
+	case "aws_iam_role":
+		if s := value.(string); strings.HasPrefix(s, "arn:") && !roleARNPattern.MatchString(s) {
+			v.report(node, path, "%q is not a valid IAM role ARN", s)
+		}
+	case "aws_iam_idp":
+		if s := value.(string); s != "" && !idpARNPattern.MatchString(s) {
+			v.report(node, path, "%q is not a valid SAML provider ARN (arn:aws:iam::123456789012:saml-provider/Name)", s)
+		}
+	case "session_policy_arns":
+		for i, arn := range value.([]string) {
+			if !policyARNPattern.MatchString(arn) {
+				v.report(node.Content[i], path, "%q is not a valid IAM policy ARN", arn)
+			}
+		}
+	case "sts_endpoint":
+		if s := value.(string); s != "" {
+			if err := validateEndpointURL(s); err != nil {
+				v.report(node, path, "%v", err)
+			}
+		}
+	case "session_policy_file", "saml_idp_cert":
+		if s := value.(string); s != "" {
+			if _, err := os.Stat(s); err != nil {
+				v.report(node, path, "%v", err)
+			}
+		}
+	}
+}
+
+func (v *configValidator) checkOneOf(node *yaml.Node, path, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.report(node, path, "%q is not one of: %s", value, strings.Join(allowed, ", "))
+}
+
+func (v *configValidator) checkRoleChain(node *yaml.Node, path string) {
+	hopFields := map[string]reflect.Type{}
+	t := reflect.TypeOf(RoleChainHop{})
+	for i := 0; i < t.NumField(); i++ {
+		hopFields[strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]] = t.Field(i).Type
+	}
+	for i, hop := range node.Content {
+		hopPath := fmt.Sprintf("%s[%d]", path, i)
+		if mappingNode(hop, "role_arn") == nil {
+			v.report(hop, hopPath, "role_arn is required")
+		}
+		for j := 0; j+1 < len(hop.Content); j += 2 {
+			key, value := hop.Content[j], hop.Content[j+1]
+			if _, ok := hopFields[key.Value]; !ok {
+				v.report(key, hopPath+"."+key.Value, "unknown key")
+				continue
+			}
+			switch key.Value {
+			case "role_arn":
+				if !roleARNPattern.MatchString(value.Value) {
+					v.report(value, hopPath+".role_arn", "%q is not a valid IAM role ARN", value.Value)
+				}
+			case "mfa_serial":
+				if !mfaSerialPattern.MatchString(value.Value) {
+					v.report(value, hopPath+".mfa_serial", "%q is not a valid MFA device ARN", value.Value)
+				}
+			case "duration":
+				var d int
+				if value.Decode(&d) != nil || d < minSessionDuration || d > roleChainMaxDuration {
+					v.report(value, hopPath+".duration", "must be %d-%d seconds for a chained role", minSessionDuration, roleChainMaxDuration)
+				}
+			}
+		}
+	}
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int:
+		return "a number"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "a mapping"
+	default:
+		return "a string"
+	}
+}
+
+func closestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for candidate := range fields {
+		if d := editDistance(key, candidate); d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// CheckConnectivity makes read-only, unauthenticated requests for every
+// context: the org's OpenID discovery document and the app's SAML metadata.
+func CheckConnectivity(file *ConfigFile) []ConfigProblem {
+	var problems []ConfigProblem
+	names := file.ContextNames()
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	for _, name := range names {
+		cfg, err := file.Resolve(name)
+		if err != nil {
+			continue
+		}
+		path := "contexts." + name
+		if name == "" {
+			path = ""
+		}
+		for _, err := range checkConfigConnectivity(cfg) {
+			problems = append(problems, ConfigProblem{Path: path, Message: err.Error()})
+		}
+	}
+	return problems
+}
+
+// ConfigNotes reports settings that are valid but depend on something outside
+// the config file, such as an IAM trust policy.
+func ConfigNotes(file *ConfigFile) []ConfigProblem {
+	var notes []ConfigProblem
+	names := file.ContextNames()
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	for _, name := range names {
+		cfg, err := file.Resolve(name)
+		if err != nil {
+			continue
+		}
+		path := "contexts." + name
+		if name == "" {
+			path = ""
+		}
+		if cfg.ReadOnlyProfile && len(cfg.RoleChain) > 0 {
+			last := cfg.RoleChain[len(cfg.RoleChain)-1].RoleARN
+			notes = append(notes, ConfigProblem{Path: path, Message: fmt.Sprintf("read_only_profile with role_chain: %s assumes itself for the read-only session, so its trust policy must allow that", last)})
+		}
+	}
+	return notes
+}
+
+func checkConfigConnectivity(cfg *Config) []error {
+	if cfg.OrgDomain == "" {
+		return nil
+	}
+	a := NewAuthenticator(cfg)
+	var errs []error
+	discoveryURL := fmt.Sprintf("https://%s/.well-known/openid-configuration", cfg.OrgDomain)
+	resp, err := a.httpClient.Get(discoveryURL)
+	if err != nil {
+		return append(errs, fmt.Errorf("cannot reach Okta org %s: %w", cfg.OrgDomain, err))
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		errs = append(errs, fmt.Errorf("%s returned status %d: is %s an Okta org?", discoveryURL, resp.StatusCode, cfg.OrgDomain))
+	}
+	appIDs := cfg.AWSAcctFedAppIDs
+	if cfg.AWSAcctFedAppID != "" {
+		appIDs = append([]string{cfg.AWSAcctFedAppID}, appIDs...)
+	}
+	for _, appID := range appIDs {
+		if _, err := a.fetchIdPMetadataCertificates(appID); err != nil {
+			errs = append(errs, fmt.Errorf("app %s: %w", appID, err))
+		}
+	}
+	return errs
+}
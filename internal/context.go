@@ -0,0 +1,357 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const contextInheritsKey = "inherits"
+
+// ConfigFile is the on-disk layout: top-level settings shared by every
+// context, plus named contexts that override them and each other.
+type ConfigFile struct {
+	Version        int `yaml:"version"`
+	Config         `yaml:",inline"`
+	CurrentContext string               `yaml:"current_context,omitempty"`
+	Contexts       map[string]yaml.Node `yaml:"contexts,omitempty"`
+
+	root yaml.Node
+}
+
+func LoadConfigFile() (*ConfigFile, error) {
+	configPath := GetConfigPath()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = migrateConfigFile(configPath, data); err != nil {
+		return nil, err
+	}
+	var file ConfigFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &file.root); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// LoadOrNewConfigFile loads the config file, or starts an empty one when there
+// is none yet. Any other error is returned, so a file oktaws cannot read (a
+// syntax error, a newer version) is never treated as empty and saved over.
+func LoadOrNewConfigFile() (*ConfigFile, error) {
+	file, err := LoadConfigFile()
+	if errors.Is(err, os.ErrNotExist) {
+		return &ConfigFile{}, nil
+	}
+	return file, err
+}
+
+func (f *ConfigFile) Save() error {
+	data, err := f.Marshal()
+	if err != nil {
+		return err
+	}
+	configPath := GetConfigPath()
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, data, 0600)
+}
+
+// Marshal renders the file as Save writes it, leaving out empty top-level keys
+// unless the file sets them to that empty value, since an explicit false or
+// empty value still overrides the system config.
+func (f *ConfigFile) Marshal() ([]byte, error) {
+	f.Version = CurrentConfigVersion
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	root := doc.Content[0]
+	var kept []*yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if !isZeroNode(root.Content[i+1]) || f.setsZero(root.Content[i].Value) {
+			kept = append(kept, root.Content[i], root.Content[i+1])
+		}
+	}
+	root.Content = kept
+	return yaml.Marshal(&doc)
+}
+
+func (f *ConfigFile) setsZero(key string) bool {
+	node := mappingNode(&f.root, key)
+	return node != nil && isZeroNode(node)
+}
+
+// SetValue stores a top-level key, remembering that the file sets it so an
+// empty or false value is saved too.
+func (f *ConfigFile) SetValue(key, value string) error {
+	key = normalizeConfigKey(key)
+	if err := f.Config.SetValue(key, value); err != nil {
+		return err
+	}
+	var encoded yaml.Node
+	if err := encoded.Encode(&f.Config); err != nil {
+		return err
+	}
+	if valueNode := mappingNode(&encoded, key); valueNode != nil {
+		setMappingValue(f.rootMapping(), key, valueNode)
+	}
+	return nil
+}
+
+func (f *ConfigFile) UnsetValue(key string) error {
+	key = normalizeConfigKey(key)
+	if err := f.Config.UnsetValue(key); err != nil {
+		return err
+	}
+	removeMappingValue(f.rootMapping(), key)
+	return nil
+}
+
+func (f *ConfigFile) rootMapping() *yaml.Node {
+	if len(f.root.Content) == 0 {
+		f.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	return f.root.Content[0]
+}
+
+func (f *ConfigFile) ContextNames() []string {
+	names := make([]string, 0, len(f.Contexts))
+	for name := range f.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *ConfigFile) HasContext(name string) bool {
+	_, ok := f.Contexts[name]
+	return ok
+}
+
+// Resolve layers the named context (or the current one when name is empty)
+// and its ancestors over the top-level settings.
+func (f *ConfigFile) Resolve(name string) (*Config, error) {
+	if name == "" {
+		name = f.CurrentContext
+	}
+	return f.resolve(name, nil)
+}
+
+func (f *ConfigFile) resolve(name string, sources ConfigSources) (*Config, error) {
+	cfg := f.Config
+	for _, key := range nodeKeys(&f.root) {
+		sources.set(key, "file "+GetConfigPath())
+	}
+	if name == "" {
+		return &cfg, nil
+	}
+	chain, err := f.contextChain(name)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		node := f.Contexts[chain[i]]
+		if err := node.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("invalid context %q: %w", chain[i], err)
+		}
+		for _, key := range nodeKeys(&node) {
+			sources.set(key, "context "+chain[i])
+		}
+	}
+	return &cfg, nil
+}
+
+func (f *ConfigFile) contextChain(name string) ([]string, error) {
+	var chain []string
+	seen := map[string]bool{}
+	for name != "" {
+		if seen[name] {
+			return nil, fmt.Errorf("context inheritance cycle: %s", strings.Join(append(chain, name), " -> "))
+		}
+		node, ok := f.Contexts[name]
+		if !ok {
+			if len(chain) == 0 {
+				return nil, fmt.Errorf("unknown context %q (available: %s)", name, strings.Join(f.ContextNames(), ", "))
+			}
+			return nil, fmt.Errorf("context %q inherits from unknown context %q", chain[len(chain)-1], name)
+		}
+		seen[name] = true
+		chain = append(chain, name)
+		name = mappingValue(&node, contextInheritsKey)
+	}
+	return chain, nil
+}
+
+func (f *ConfigFile) ContextParent(name string) string {
+	node, ok := f.Contexts[name]
+	if !ok {
+		return ""
+	}
+	return mappingValue(&node, contextInheritsKey)
+}
+
+// SetContextValue stores a single key in the context's own settings, leaving
+// everything it inherits untouched.
+func (f *ConfigFile) SetContextValue(name, key, value string) error {
+	key = normalizeConfigKey(key)
+	node := f.contextNode(name)
+	if key == contextInheritsKey {
+		if value == "" {
+			removeMappingValue(node, key)
+			f.Contexts[name] = *node
+			return nil
+		}
+		setMappingValue(node, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+		previous, existed := f.Contexts[name]
+		f.Contexts[name] = *node
+		if _, err := f.contextChain(name); err != nil {
+			if existed {
+				f.Contexts[name] = previous
+			} else {
+				delete(f.Contexts, name)
+			}
+			return err
+		}
+		return nil
+	}
+	var scratch Config
+	if err := scratch.SetValue(key, value); err != nil {
+		return err
+	}
+	var encoded yaml.Node
+	if err := encoded.Encode(&scratch); err != nil {
+		return err
+	}
+	valueNode := mappingNode(&encoded, key)
+	if valueNode == nil {
+		return fmt.Errorf("configuration key %s cannot be set per context", key)
+	}
+	setMappingValue(node, key, valueNode)
+	f.Contexts[name] = *node
+	return nil
+}
+
+func (f *ConfigFile) UnsetContextValue(name, key string) error {
+	key = normalizeConfigKey(key)
+	if !f.HasContext(name) {
+		return fmt.Errorf("unknown context %q", name)
+	}
+	if key != contextInheritsKey {
+		if _, err := (&Config{}).configField(key); err != nil {
+			return err
+		}
+	}
+	node := f.contextNode(name)
+	removeMappingValue(node, key)
+	f.Contexts[name] = *node
+	return nil
+}
+
+// SetContext replaces a context's own settings with the non-zero fields of cfg.
+func (f *ConfigFile) SetContext(name string, cfg *Config) error {
+	var encoded yaml.Node
+	if err := encoded.Encode(cfg); err != nil {
+		return err
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if parent := f.ContextParent(name); parent != "" {
+		setMappingValue(node, contextInheritsKey, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: parent})
+	}
+	for i := 0; i+1 < len(encoded.Content); i += 2 {
+		if isZeroNode(encoded.Content[i+1]) {
+			continue
+		}
+		node.Content = append(node.Content, encoded.Content[i], encoded.Content[i+1])
+	}
+	if f.Contexts == nil {
+		f.Contexts = map[string]yaml.Node{}
+	}
+	f.Contexts[name] = *node
+	return nil
+}
+
+func (f *ConfigFile) contextNode(name string) *yaml.Node {
+	if f.Contexts == nil {
+		f.Contexts = map[string]yaml.Node{}
+	}
+	node, ok := f.Contexts[name]
+	if !ok || node.Kind != yaml.MappingNode {
+		node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	return &node
+}
+
+func mappingNode(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func nodeKeys(node *yaml.Node) []string {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	var keys []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i].Value; key != contextInheritsKey && key != "current_context" && key != "contexts" && key != "version" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func mappingValue(node *yaml.Node, key string) string {
+	if value := mappingNode(node, key); value != nil {
+		return value.Value
+	}
+	return ""
+}
+
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+func removeMappingValue(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func isZeroNode(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value == "" || node.Value == "0" || node.Value == "false" || node.Tag == "!!null"
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(node.Content) == 0
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"gopkg.in/ini.v1"
+)
+
+// credentialsExpiryKey records when the session written to a credentials
+// profile expires, under the name saml2aws and gimme-aws-creds also use.
+const credentialsExpiryKey = "x_security_token_expires"
+
+// credentialsRoleKey records the SAML role a profile's session was assumed
+// into, so cached credentials are never reused for a different role.
+const credentialsRoleKey = "x_oktaws_role_arn"
+
+// credentialsRefreshMargin is how long before expiry cached credentials are
+// treated as expired, so a shell is not handed a session about to lapse.
+const credentialsRefreshMargin = 5 * time.Minute
+
+// DirenvHook is the direnvrc function behind `use oktaws` in an .envrc.
+const DirenvHook = `# oktaws: add to ~/.config/direnv/direnvrc, then put "use oktaws" in a project's .envrc
+use_oktaws() {
+  watch_file .oktaws.yaml
+  eval "$(oktaws direnv export "$@")"
+}
+`
+
+// cachedCredentials returns the credentials stored in profile if they carry
+// an expiry that is not yet due and, when roleARN is set, were assumed into
+// that role (matched the way aws_iam_role selects one).
+func cachedCredentials(profile, roleARN string) (*sts.Credentials, bool) {
+	file, err := ini.Load(awsCredentialsPath())
+	if err != nil {
+		return nil, false
+	}
+	section, err := file.GetSection(profile)
+	if err != nil {
+		return nil, false
+	}
+	expires, err := time.Parse(time.RFC3339, section.Key(credentialsExpiryKey).String())
+	if err != nil || time.Until(expires) < credentialsRefreshMargin {
+		return nil, false
+	}
+	if cachedRole := section.Key(credentialsRoleKey).String(); roleARN != "" && (cachedRole == "" || !strings.Contains(cachedRole, roleARN)) {
+		return nil, false
+	}
+	creds := &sts.Credentials{
+		AccessKeyId:     aws.String(section.Key("aws_access_key_id").String()),
+		SecretAccessKey: aws.String(section.Key("aws_secret_access_key").String()),
+		SessionToken:    aws.String(section.Key("aws_session_token").String()),
+		Expiration:      aws.Time(expires),
+	}
+	if *creds.AccessKeyId == "" || *creds.SecretAccessKey == "" {
+		return nil, false
+	}
+	return creds, true
+}
+
+// DirenvExport prints shell exports for the configured profile, signing in
+// again only when the cached session in the credentials file has expired or
+// belongs to another role than the configured aws_iam_role.
+// Anything the sign-in prints goes to stderr so stdout stays eval-safe.
+func DirenvExport(cfg *Config) error {
+	creds, ok := cachedCredentials(cfg.Profile, cfg.AWSIAMRole)
+	if !ok {
+		cfg.WriteAWSCredentials = true
+		a := NewAuthenticator(cfg)
+		a.out = os.Stderr
+		if err := a.Authenticate(); err != nil {
+			return err
+		}
+		if creds, ok = cachedCredentials(cfg.Profile, cfg.AWSIAMRole); !ok {
+			return fmt.Errorf("no unexpired credentials for %s in profile %s after signing in", cfg.AWSIAMRole, cfg.Profile)
+		}
+	}
+	fmt.Printf("export AWS_PROFILE='%s'\n", cfg.Profile)
+	fmt.Printf("export AWS_ACCESS_KEY_ID='%s'\n", *creds.AccessKeyId)
+	fmt.Printf("export AWS_SECRET_ACCESS_KEY='%s'\n", *creds.SecretAccessKey)
+	fmt.Printf("export AWS_SESSION_TOKEN='%s'\n", *creds.SessionToken)
+	fmt.Printf("export AWS_SESSION_EXPIRATION='%s'\n", creds.Expiration.UTC().Format(time.RFC3339))
+	if cfg.AWSRegion != "" {
+		fmt.Printf("export AWS_REGION='%s'\n", cfg.AWSRegion)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseEmbedLink extracts the org domain and app ID from an Okta app embed
+// link, e.g. https://acme.okta.com/home/amazon_aws/0oa1b2c3d4e5f6g7h8i9/272
+// or https://acme.okta.com/app/amazon_aws/exk1b2c3d4e5f6g7h8i9/sso/saml.
+func ParseEmbedLink(link string) (string, string, error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", "", fmt.Errorf("invalid embed link %q: must be a URL like https://your-org.okta.com/home/amazon_aws/0oa.../272", link)
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 3 || (segments[0] != "home" && segments[0] != "app") {
+		return "", "", fmt.Errorf("invalid embed link %q: expected a /home/<app>/<app-id>/... or /app/<app>/<app-id>/... path", link)
+	}
+	appID := segments[2]
+	if !oktaAppIDPattern.MatchString(appID) {
+		return "", "", fmt.Errorf("invalid embed link %q: %q is not an Okta app ID", link, appID)
+	}
+	return u.Hostname(), appID, nil
+}
+
+// LoadConfigSeed reads a YAML or JSON file in the config.yaml layout for
+// non-interactive setup, rejecting it if validation finds any problem.
+func LoadConfigSeed(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed file: %w", err)
+	}
+	if problems := ValidateConfig(data); len(problems) > 0 {
+		var messages []string
+		for _, problem := range problems {
+			messages = append(messages, problem.String())
+		}
+		return nil, fmt.Errorf("invalid seed file %s:\n  %s", path, strings.Join(messages, "\n  "))
+	}
+	var seed ConfigFile
+	if err := yaml.Unmarshal(data, &seed); err != nil {
+		return nil, fmt.Errorf("invalid seed file %s: %w", path, err)
+	}
+	return &seed, nil
+}
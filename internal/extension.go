@@ -2,9 +2,11 @@ package internal
 
 import (
 	"archive/zip"
+	"bytes"
 	"embed"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
@@ -104,12 +106,12 @@ func detectBrowserWindows() (BrowserType, string, error) {
 	return BrowserUnknown, "", fmt.Errorf("no supported browser found (Chrome or Firefox required)")
 }
 func GetExtensionPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return "", fmt.Errorf("failed to get home directory")
 	}
 
-	extPath := filepath.Join(homeDir, ".config", "oktaws", "extension")
+	extPath := filepath.Join(filepath.Dir(configPath), "extension")
 
 	if err := ensureExtensionExtracted(extPath); err != nil {
 		return "", fmt.Errorf("failed to setup extension: %w", err)
@@ -120,9 +122,15 @@ func GetExtensionPath() (string, error) {
 
 func ensureExtensionExtracted(extPath string) error {
 	manifestPath := filepath.Join(extPath, "manifest.json")
-	if _, err := os.Stat(manifestPath); err == nil {
+	embeddedManifest, err := extensionFS.ReadFile("extension/manifest.json")
+	if err != nil {
+		return fmt.Errorf("failed to read embedded manifest.json: %w", err)
+	}
+	existingManifest, err := os.ReadFile(manifestPath)
+	if err == nil && bytes.Equal(existingManifest, embeddedManifest) {
 		return nil
 	}
+	extensionUpdated := err == nil
 
 	if err := os.MkdirAll(extPath, 0755); err != nil {
 		return err
@@ -151,25 +159,29 @@ func ensureExtensionExtracted(extPath string) error {
 		}
 	}
 
+	if extensionUpdated {
+		fmt.Fprintf(os.Stderr, "Extension files at %s were updated. Reload the extension in your browser to pick up the changes.\n", extPath)
+	}
+
 	return nil
 }
-func InstallExtension(browserType BrowserType) error {
+func InstallExtension(w io.Writer, browserType BrowserType) error {
 	extPath, err := GetExtensionPath()
 	if err != nil {
 		return fmt.Errorf("failed to get extension path: %w", err)
 	}
 
-	fmt.Println()
-	fmt.Println("╔════════════════════════════════════════════════════════════╗")
-	fmt.Println("║        Extension Setup Required (One-Time)                 ║")
-	fmt.Println("╚════════════════════════════════════════════════════════════╝")
-	fmt.Println()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "╔════════════════════════════════════════════════════════════╗")
+	fmt.Fprintln(w, "║        Extension Setup Required (One-Time)                 ║")
+	fmt.Fprintln(w, "╚════════════════════════════════════════════════════════════╝")
+	fmt.Fprintln(w)
 
 	switch browserType {
 	case BrowserChrome:
-		return installChromeExtension(extPath)
+		return installChromeExtension(w, extPath)
 	case BrowserFirefox:
-		return installFirefoxExtension(extPath)
+		return installFirefoxExtension(w, extPath)
 	default:
 		return fmt.Errorf("unsupported browser type")
 	}
@@ -316,17 +328,17 @@ func LaunchBrowserWithExtension(browserType BrowserType, url string) error {
 	return cmd.Start()
 }
 
-func installChromeExtension(extPath string) error {
+func installChromeExtension(w io.Writer, extPath string) error {
 	if isChromeRunning() {
-		fmt.Println("Chrome is currently running.")
-		fmt.Print("Please close Chrome and press Enter to continue... ")
+		fmt.Fprintln(w, "Chrome is currently running.")
+		fmt.Fprint(w, "Please close Chrome and press Enter to continue... ")
 		var input string
 		fmt.Scanln(&input)
 
 		time.Sleep(1 * time.Second)
 
 		if isChromeRunning() {
-			fmt.Println("⚠ Chrome is still running. Waiting...")
+			fmt.Fprintln(w, "⚠ Chrome is still running. Waiting...")
 			time.Sleep(2 * time.Second)
 		}
 	}
@@ -346,14 +358,14 @@ func installChromeExtension(extPath string) error {
 	}
 
 	if err := enableChromeDevMode(prefsPath); err != nil {
-		fmt.Println("⚠ Could not auto-enable Developer Mode")
-		fmt.Println("  You'll need to enable it manually in the next step")
+		fmt.Fprintln(w, "⚠ Could not auto-enable Developer Mode")
+		fmt.Fprintln(w, "  You'll need to enable it manually in the next step")
 	} else {
-		fmt.Println("✓ Developer Mode enabled")
+		fmt.Fprintln(w, "✓ Developer Mode enabled")
 	}
 
-	fmt.Println()
-	fmt.Println("Opening Chrome extensions page...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Opening Chrome extensions page...")
 
 	if err := openChromeExtensionsPage(); err != nil {
 		return fmt.Errorf("failed to open Chrome: %w", err)
@@ -361,22 +373,22 @@ func installChromeExtension(extPath string) error {
 
 	time.Sleep(2 * time.Second)
 
-	fmt.Println()
-	fmt.Println("────────────────────────────────────────────────────────────")
-	fmt.Println()
-	fmt.Println("✓ Extension folder ready at:")
-	fmt.Println("  " + extPath)
-	fmt.Println()
-	fmt.Println("In the Chrome tab that just opened:")
-	fmt.Println("  1. Developer Mode should already be ON (top-right)")
-	fmt.Println("  2. Click 'Load unpacked' button")
-	fmt.Println("  3. Select the folder path shown above")
-	fmt.Println()
-	fmt.Println("────────────────────────────────────────────────────────────")
-	fmt.Println()
-	fmt.Println("💡 Tip: Copy the path above, then paste it in the folder picker")
-	fmt.Println()
-	fmt.Print("Press Enter once the extension is loaded... ")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "────────────────────────────────────────────────────────────")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "✓ Extension folder ready at:")
+	fmt.Fprintln(w, "  "+extPath)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "In the Chrome tab that just opened:")
+	fmt.Fprintln(w, "  1. Developer Mode should already be ON (top-right)")
+	fmt.Fprintln(w, "  2. Click 'Load unpacked' button")
+	fmt.Fprintln(w, "  3. Select the folder path shown above")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "────────────────────────────────────────────────────────────")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "💡 Tip: Copy the path above, then paste it in the folder picker")
+	fmt.Fprintln(w)
+	fmt.Fprint(w, "Press Enter once the extension is loaded... ")
 
 	var input string
 	fmt.Scanln(&input)
@@ -420,23 +432,23 @@ func enableChromeDevMode(prefsPath string) error {
 	return os.WriteFile(prefsPath, updatedData, 0644)
 }
 
-func installFirefoxExtension(extPath string) error {
+func installFirefoxExtension(w io.Writer, extPath string) error {
 	if isFirefoxRunning() {
-		fmt.Println("Firefox is currently running.")
-		fmt.Print("Please close Firefox and press Enter to continue... ")
+		fmt.Fprintln(w, "Firefox is currently running.")
+		fmt.Fprint(w, "Please close Firefox and press Enter to continue... ")
 		var input string
 		fmt.Scanln(&input)
 
 		time.Sleep(1 * time.Second)
 
 		if isFirefoxRunning() {
-			fmt.Println("⚠ Firefox is still running. Waiting...")
+			fmt.Fprintln(w, "⚠ Firefox is still running. Waiting...")
 			time.Sleep(2 * time.Second)
 		}
 	}
 
-	fmt.Println()
-	fmt.Println("Opening Firefox debugging page...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Opening Firefox debugging page...")
 
 	if err := openFirefoxDebuggingPage(); err != nil {
 		return fmt.Errorf("failed to open Firefox: %w", err)
@@ -446,24 +458,24 @@ func installFirefoxExtension(extPath string) error {
 
 	manifestPath := filepath.Join(extPath, "manifest.json")
 
-	fmt.Println()
-	fmt.Println("────────────────────────────────────────────────────────────")
-	fmt.Println()
-	fmt.Println("✓ Extension manifest ready at:")
-	fmt.Println("  " + manifestPath)
-	fmt.Println()
-	fmt.Println("In the Firefox tab that just opened:")
-	fmt.Println("  1. Click 'Load Temporary Add-on...' button")
-	fmt.Println("  2. Select the manifest.json file from the path above")
-	fmt.Println()
-	fmt.Println("Note: This extension will need to be reloaded each time")
-	fmt.Println("      Firefox restarts (browser security limitation)")
-	fmt.Println()
-	fmt.Println("────────────────────────────────────────────────────────────")
-	fmt.Println()
-	fmt.Println("💡 Tip: Copy the path above, then select manifest.json")
-	fmt.Println()
-	fmt.Print("Press Enter once the extension is loaded... ")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "────────────────────────────────────────────────────────────")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "✓ Extension manifest ready at:")
+	fmt.Fprintln(w, "  "+manifestPath)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "In the Firefox tab that just opened:")
+	fmt.Fprintln(w, "  1. Click 'Load Temporary Add-on...' button")
+	fmt.Fprintln(w, "  2. Select the manifest.json file from the path above")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Note: This extension will need to be reloaded each time")
+	fmt.Fprintln(w, "      Firefox restarts (browser security limitation)")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "────────────────────────────────────────────────────────────")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "💡 Tip: Copy the path above, then select manifest.json")
+	fmt.Fprintln(w)
+	fmt.Fprint(w, "Press Enter once the extension is loaded... ")
 
 	var input string
 	fmt.Scanln(&input)
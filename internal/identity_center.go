@@ -0,0 +1,314 @@
+package internal
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sso"
+	"github.com/aws/aws-sdk-go/service/ssooidc"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// identityCenterToken is an access token for the AWS access portal, stored
+// in the same format and place as `aws sso login` so either tool can reuse
+// the other's sign-in.
+type identityCenterToken struct {
+	StartURL    string `json:"startUrl"`
+	Region      string `json:"region"`
+	AccessToken string `json:"accessToken"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+// AuthenticateWithIdentityCenter signs in to IAM Identity Center, whose sign-in
+// page hands over to Okta as the identity source, and fetches credentials
+// for an account and permission set from the access portal.
+func (a *Authenticator) AuthenticateWithIdentityCenter() error {
+	if a.config.SSOStartURL == "" {
+		return fmt.Errorf("sso_start_url is required for the sso flow (e.g. https://my-org.awsapps.com/start)")
+	}
+	region := a.identityCenterRegion()
+	if region == "" {
+		return fmt.Errorf("sso_region is required for the sso flow (the region of your IAM Identity Center instance)")
+	}
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	accessToken, err := a.identityCenterAccessToken(sess, region)
+	if err != nil {
+		return err
+	}
+
+	portal := sso.New(sess)
+	accountID, err := a.selectIdentityCenterAccount(portal, accessToken)
+	if err != nil {
+		return err
+	}
+	roleName, err := a.selectIdentityCenterRole(portal, accessToken, accountID)
+	if err != nil {
+		return err
+	}
+
+	if a.config.Debug {
+		fmt.Fprintf(os.Stderr, "✓ Using permission set %s in account %s\n", roleName, accountID)
+	}
+
+	out, err := portal.GetRoleCredentials(&sso.GetRoleCredentialsInput{
+		AccessToken: aws.String(accessToken),
+		AccountId:   aws.String(accountID),
+		RoleName:    aws.String(roleName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get role credentials: %w", err)
+	}
+
+	roleCreds := out.RoleCredentials
+	creds := &sts.Credentials{
+		AccessKeyId:     roleCreds.AccessKeyId,
+		SecretAccessKey: roleCreds.SecretAccessKey,
+		SessionToken:    roleCreds.SessionToken,
+		Expiration:      aws.Time(time.UnixMilli(aws.Int64Value(roleCreds.Expiration))),
+	}
+	return a.outputCredentials(creds)
+}
+
+func (a *Authenticator) identityCenterRegion() string {
+	if a.config.SSORegion != "" {
+		return a.config.SSORegion
+	}
+	return a.config.AWSRegion
+}
+
+// identityCenterAccessToken returns a cached portal token when cache_access_token
+// is set and one is still valid, and otherwise runs the SSO OIDC device flow.
+// The Okta token is not exchanged with CreateTokenWithIAM: that call needs
+// AWS credentials up front and the portal rejects the token it issues.
+func (a *Authenticator) identityCenterAccessToken(sess *session.Session, region string) (string, error) {
+	if a.config.CacheAccessToken {
+		if token, ok := a.cachedIdentityCenterToken(); ok {
+			if a.config.Debug {
+				fmt.Fprintf(os.Stderr, "✓ Using cached IAM Identity Center token\n")
+			}
+			return token, nil
+		}
+	}
+
+	client := ssooidc.New(sess)
+	registration, err := client.RegisterClient(&ssooidc.RegisterClientInput{
+		ClientName: aws.String("oktaws"),
+		ClientType: aws.String("public"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to register with IAM Identity Center: %w", err)
+	}
+
+	authorization, err := client.StartDeviceAuthorization(&ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     registration.ClientId,
+		ClientSecret: registration.ClientSecret,
+		StartUrl:     aws.String(a.config.SSOStartURL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("device authorization failed: %w", err)
+	}
+
+	if err := a.displayAuthorizationURL(&deviceAuthResponse{
+		UserCode:                aws.StringValue(authorization.UserCode),
+		VerificationURI:         aws.StringValue(authorization.VerificationUri),
+		VerificationURIComplete: aws.StringValue(authorization.VerificationUriComplete),
+	}); err != nil {
+		return "", err
+	}
+
+	interval := time.Duration(aws.Int64Value(authorization.Interval)) * time.Second
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(aws.Int64Value(authorization.ExpiresIn)) * time.Second)
+
+	fmt.Fprint(a.out, "Waiting for authentication")
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		fmt.Fprint(a.out, ".")
+
+		token, err := client.CreateToken(&ssooidc.CreateTokenInput{
+			ClientId:     registration.ClientId,
+			ClientSecret: registration.ClientSecret,
+			DeviceCode:   authorization.DeviceCode,
+			GrantType:    aws.String(deviceCodeGrantType),
+		})
+		if err != nil {
+			var aerr awserr.Error
+			if errors.As(err, &aerr) {
+				switch aerr.Code() {
+				case ssooidc.ErrCodeAuthorizationPendingException:
+					continue
+				case ssooidc.ErrCodeSlowDownException:
+					interval += 5 * time.Second
+					continue
+				}
+			}
+			fmt.Fprintln(a.out)
+			return "", fmt.Errorf("authentication failed: %w", err)
+		}
+
+		fmt.Fprintln(a.out, " ✓")
+		accessToken := aws.StringValue(token.AccessToken)
+		if a.config.CacheAccessToken {
+			expiresAt := time.Now().Add(time.Duration(aws.Int64Value(token.ExpiresIn)) * time.Second)
+			if err := a.cacheIdentityCenterToken(accessToken, region, expiresAt); err != nil && a.config.Debug {
+				fmt.Fprintf(os.Stderr, "Warning: failed to cache token: %v\n", err)
+			}
+		}
+		return accessToken, nil
+	}
+
+	fmt.Fprintln(a.out)
+	return "", fmt.Errorf("authentication timed out")
+}
+
+func (a *Authenticator) identityCenterTokenPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(a.config.SSOStartURL))
+	return filepath.Join(homeDir, ".aws", "sso", "cache", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+func (a *Authenticator) cachedIdentityCenterToken() (string, bool) {
+	path, err := a.identityCenterTokenPath()
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	var token identityCenterToken
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, token.ExpiresAt)
+	if err != nil || time.Until(expiresAt) < credentialsRefreshMargin {
+		return "", false
+	}
+	return token.AccessToken, true
+}
+
+func (a *Authenticator) cacheIdentityCenterToken(accessToken, region string, expiresAt time.Time) error {
+	path, err := a.identityCenterTokenPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(identityCenterToken{
+		StartURL:    a.config.SSOStartURL,
+		Region:      region,
+		AccessToken: accessToken,
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC3339),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func (a *Authenticator) selectIdentityCenterAccount(portal *sso.SSO, accessToken string) (string, error) {
+	if a.config.SSOAccountID != "" {
+		return a.config.SSOAccountID, nil
+	}
+
+	var accounts []*sso.AccountInfo
+	err := portal.ListAccountsPages(&sso.ListAccountsInput{AccessToken: aws.String(accessToken)},
+		func(page *sso.ListAccountsOutput, lastPage bool) bool {
+			accounts = append(accounts, page.AccountList...)
+			return true
+		})
+	if err != nil {
+		return "", fmt.Errorf("failed to list accounts: %w", err)
+	}
+	if len(accounts) == 0 {
+		return "", fmt.Errorf("no AWS accounts are assigned to you in IAM Identity Center")
+	}
+	if len(accounts) == 1 {
+		return aws.StringValue(accounts[0].AccountId), nil
+	}
+
+	fmt.Fprintln(a.out, "\nAvailable AWS accounts:")
+	for i, account := range accounts {
+		fmt.Fprintf(a.out, "  [%d] %s (%s)\n", i+1, aws.StringValue(account.AccountId), aws.StringValue(account.AccountName))
+	}
+	choice, err := a.promptChoice("Select an account", len(accounts), "--sso-account-id")
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(accounts[choice].AccountId), nil
+}
+
+func (a *Authenticator) selectIdentityCenterRole(portal *sso.SSO, accessToken, accountID string) (string, error) {
+	if a.config.SSORoleName != "" {
+		return a.config.SSORoleName, nil
+	}
+
+	var roles []*sso.RoleInfo
+	err := portal.ListAccountRolesPages(&sso.ListAccountRolesInput{
+		AccessToken: aws.String(accessToken),
+		AccountId:   aws.String(accountID),
+	}, func(page *sso.ListAccountRolesOutput, lastPage bool) bool {
+		roles = append(roles, page.RoleList...)
+		return true
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list permission sets for account %s: %w", accountID, err)
+	}
+	if len(roles) == 0 {
+		return "", fmt.Errorf("no permission sets are assigned to you in account %s", accountID)
+	}
+	if len(roles) == 1 {
+		return aws.StringValue(roles[0].RoleName), nil
+	}
+
+	fmt.Fprintln(a.out, "\nAvailable permission sets:")
+	for i, role := range roles {
+		fmt.Fprintf(a.out, "  [%d] %s\n", i+1, aws.StringValue(role.RoleName))
+	}
+	choice, err := a.promptChoice("Select a permission set", len(roles), "--sso-role-name")
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(roles[choice].RoleName), nil
+}
+
+// promptChoice asks for a number between 1 and n, defaulting to 1, and
+// returns it as an index. Without a terminal it fails, naming the flag that
+// makes the choice instead.
+func (a *Authenticator) promptChoice(label string, n int, flag string) (int, error) {
+	if !isTerminal(os.Stdin) {
+		return 0, fmt.Errorf("%d choices are available and stdin is not a terminal to pick one: pass %s", n, flag)
+	}
+	fmt.Fprintf(a.out, "\n%s [1]: ", label)
+	var choice int
+	fmt.Scanln(&choice)
+	if choice == 0 {
+		choice = 1
+	}
+	if choice < 1 || choice > n {
+		return 0, fmt.Errorf("invalid selection")
+	}
+	return choice - 1, nil
+}
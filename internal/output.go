@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// printStructured writes v to stdout as JSON or YAML, or calls table for the
+// human-readable format.
+func printStructured(format string, v interface{}, table func() error) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		defer encoder.Close()
+		return encoder.Encode(v)
+	case "table", "":
+		return table()
+	default:
+		return fmt.Errorf("unknown output format: %s (valid options: table, json, yaml)", format)
+	}
+}
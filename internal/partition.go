@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+var partitionDefaultRegions = map[string]string{
+	endpoints.AwsPartitionID:      "us-east-1",
+	endpoints.AwsCnPartitionID:    "cn-north-1",
+	endpoints.AwsUsGovPartitionID: "us-gov-west-1",
+	endpoints.AwsIsoPartitionID:   "us-iso-east-1",
+	endpoints.AwsIsoBPartitionID:  "us-isob-east-1",
+	endpoints.AwsIsoEPartitionID:  "eu-isoe-west-1",
+	endpoints.AwsIsoFPartitionID:  "us-isof-south-1",
+}
+
+func partitionFromARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 3)
+	if len(parts) < 3 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[1]
+}
+
+func regionPartition(region string) string {
+	p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region)
+	if !ok {
+		return ""
+	}
+	return p.ID()
+}
+
+// stsRegion picks the region used to sign STS requests for the role's
+// partition, keeping the configured region when it belongs to that partition.
+func (a *Authenticator) stsRegion(roleARN, principalARN string) (string, error) {
+	partition := partitionFromARN(roleARN)
+	if partition == "" {
+		partition = partitionFromARN(principalARN)
+	}
+	if partition == "" {
+		partition = endpoints.AwsPartitionID
+	}
+
+	if a.config.AWSRegion != "" && regionPartition(a.config.AWSRegion) == partition {
+		return a.config.AWSRegion, nil
+	}
+
+	region, ok := partitionDefaultRegions[partition]
+	if !ok {
+		return "", fmt.Errorf("unsupported AWS partition %q in role %s", partition, roleARN)
+	}
+	if a.config.AWSRegion != "" {
+		fmt.Fprintf(os.Stderr, "Warning: region %s is not in partition %s; using %s for STS\n", a.config.AWSRegion, partition, region)
+	} else if a.config.Debug {
+		fmt.Fprintf(os.Stderr, "Using STS region %s for partition %s\n", region, partition)
+	}
+	return region, nil
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFileName is the per-repository config file. It holds a few
+// top-level config.yaml keys, plus an optional context to select.
+const ProjectConfigFileName = ".oktaws.yaml"
+
+const projectContextKey = "context"
+
+// projectConfigKeys are the keys a project file may set. A cloned repository
+// can pick the account and role it is meant for, but not where you sign in
+// or which commands run, so org, app, client, browser and endpoint settings
+// stay in config.yaml.
+var projectConfigKeys = []string{
+	projectContextKey,
+	"profile",
+	"aws_iam_role",
+	"aws_region",
+	"role_chain",
+	"session_duration",
+	"format",
+}
+
+func isProjectConfigKey(key string) bool {
+	for _, allowed := range projectConfigKeys {
+		if key == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+type ProjectConfig struct {
+	Path    string
+	Context string
+	root    yaml.Node
+}
+
+// FindProjectConfig walks from the working directory up to the repository
+// root (the first directory holding .git) looking for .oktaws.yaml.
+func FindProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectConfigFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig reads the project file for the working directory, or
+// returns nil when there is none.
+func LoadProjectConfig() (*ProjectConfig, error) {
+	path := FindProjectConfig()
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if problems := ValidateProjectConfig(data); len(problems) > 0 {
+		var messages []string
+		for _, problem := range problems {
+			messages = append(messages, problem.String())
+		}
+		return nil, fmt.Errorf("invalid project config %s:\n  %s", path, strings.Join(messages, "\n  "))
+	}
+	project := &ProjectConfig{Path: path}
+	if err := yaml.Unmarshal(data, &project.root); err != nil {
+		return nil, err
+	}
+	project.Context = mappingValue(&project.root, projectContextKey)
+	return project, nil
+}
+
+// ValidateProjectConfig checks a .oktaws.yaml the same way ValidateConfig
+// checks the top level of config.yaml, and rejects keys a project may not set.
+func ValidateProjectConfig(data []byte) []ConfigProblem {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return ValidateConfig(data)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	v := &configValidator{}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.report(root, "", "project config must be a mapping of keys to values")
+		return v.problems
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Value == projectContextKey {
+			if value.Kind != yaml.ScalarNode {
+				v.report(value, key.Value, "must be a context name")
+			}
+			continue
+		}
+		if _, known := configFields()[key.Value]; known && !isProjectConfigKey(key.Value) {
+			v.report(key, key.Value, "cannot be set in a project file (allowed: %s); set it in your own config instead", strings.Join(projectConfigKeys, ", "))
+			continue
+		}
+		v.checkKey(key, value, "")
+	}
+	return v.problems
+}
+
+// apply layers the project's settings over cfg, ignoring any key outside
+// projectConfigKeys.
+func (p *ProjectConfig) apply(cfg *Config, sources ConfigSources) error {
+	if len(p.root.Content) == 0 {
+		return nil
+	}
+	root := p.root.Content[0]
+	allowed := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key := root.Content[i].Value; key != projectContextKey && isProjectConfigKey(key) {
+			allowed.Content = append(allowed.Content, root.Content[i], root.Content[i+1])
+		}
+	}
+	if err := allowed.Decode(cfg); err != nil {
+		return fmt.Errorf("invalid project config %s: %w", p.Path, err)
+	}
+	for i := 0; i < len(allowed.Content); i += 2 {
+		sources.set(allowed.Content[i].Value, "project "+p.Path)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const roleChainMaxDuration = 3600
+
+type RoleChainHop struct {
+	RoleARN           string            `yaml:"role_arn"`
+	RoleSessionName   string            `yaml:"role_session_name,omitempty"`
+	ExternalID        string            `yaml:"external_id,omitempty"`
+	MFASerial         string            `yaml:"mfa_serial,omitempty"`
+	TokenCode         string            `yaml:"token_code,omitempty"`
+	SessionTags       map[string]string `yaml:"session_tags,omitempty"`
+	TransitiveTagKeys []string          `yaml:"transitive_tag_keys,omitempty"`
+	Duration          int               `yaml:"duration,omitempty"`
+}
+
+// assumeRole assumes the SAML role and then any role chain. Session policies
+// limit the credentials oktaws hands out, so with a chain they go on the last
+// hop rather than the SAML one.
+func (a *Authenticator) assumeRole(samlAssertion, roleARN, principalARN string) (*sts.Credentials, error) {
+	policy, err := a.configuredSessionPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if len(a.config.RoleChain) == 0 {
+		return a.assumeRoleWithSAMLPolicy(samlAssertion, roleARN, principalARN, policy)
+	}
+	creds, err := a.assumeRoleWithSAMLPolicy(samlAssertion, roleARN, principalARN, sessionPolicy{})
+	if err != nil {
+		return nil, err
+	}
+	return a.assumeRoleChain(creds, policy)
+}
+
+// assumeRoleChain runs the configured hops, attaching finalPolicy to the last
+// one so that a downscoped chain is limited where it ends up.
+func (a *Authenticator) assumeRoleChain(creds *sts.Credentials, finalPolicy sessionPolicy) (*sts.Credentials, error) {
+	for i, hop := range a.config.RoleChain {
+		var policy sessionPolicy
+		if i == len(a.config.RoleChain)-1 {
+			policy = finalPolicy
+		}
+		next, err := a.assumeChainHop(creds, hop, policy)
+		if err != nil {
+			return nil, fmt.Errorf("role chain hop %d (%s): %w", i+1, hop.RoleARN, err)
+		}
+		creds = next
+	}
+	return creds, nil
+}
+
+func (a *Authenticator) assumeChainHop(creds *sts.Credentials, hop RoleChainHop, policy sessionPolicy) (*sts.Credentials, error) {
+	if hop.RoleARN == "" {
+		return nil, fmt.Errorf("role_arn is required")
+	}
+
+	sess, err := a.newSTSSession(hop.RoleARN, "", creds)
+	if err != nil {
+		return nil, err
+	}
+	stsClient := sts.New(sess)
+
+	duration := hop.Duration
+	if duration == 0 {
+		duration = roleChainMaxDuration
+	}
+
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(hop.RoleARN),
+		RoleSessionName: aws.String(a.chainSessionName(hop)),
+		DurationSeconds: aws.Int64(int64(duration)),
+		PolicyArns:      policyDescriptors(policy.arns),
+		Policy:          policy.document(),
+	}
+	if hop.ExternalID != "" {
+		input.ExternalId = aws.String(hop.ExternalID)
+	}
+	if hop.MFASerial != "" {
+		tokenCode := hop.TokenCode
+		if tokenCode == "" {
+			tokenCode, err = promptMFATokenCode(hop.MFASerial)
+			if err != nil {
+				return nil, err
+			}
+		}
+		input.SerialNumber = aws.String(hop.MFASerial)
+		input.TokenCode = aws.String(tokenCode)
+	}
+	for _, key := range sortedKeys(hop.SessionTags) {
+		input.Tags = append(input.Tags, &sts.Tag{Key: aws.String(key), Value: aws.String(hop.SessionTags[key])})
+	}
+	if len(hop.TransitiveTagKeys) > 0 {
+		input.TransitiveTagKeys = aws.StringSlice(hop.TransitiveTagKeys)
+	}
+
+	result, err := stsClient.AssumeRole(input)
+	if err != nil {
+		return nil, err
+	}
+
+	a.recordChainHop(result, hop)
+	if a.config.Debug {
+		fmt.Fprintf(os.Stderr, "✓ Chained into role: %s\n", aws.StringValue(result.AssumedRoleUser.Arn))
+	}
+
+	return result.Credentials, nil
+}
+
+func (a *Authenticator) chainSessionName(hop RoleChainHop) string {
+	if hop.RoleSessionName != "" {
+		return hop.RoleSessionName
+	}
+	if a.session != nil && a.session.RoleSessionName != "" {
+		return a.session.RoleSessionName
+	}
+	return "oktaws"
+}
+
+func (a *Authenticator) recordChainHop(result *sts.AssumeRoleOutput, hop RoleChainHop) {
+	if a.session == nil {
+		a.session = &sessionMetadata{}
+	}
+	meta := a.session
+
+	tags := make(map[string]string)
+	for _, key := range meta.TransitiveTagKeys {
+		if value, ok := meta.SessionTags[key]; ok {
+			tags[key] = value
+		}
+	}
+	for key, value := range hop.SessionTags {
+		tags[key] = value
+	}
+	meta.SessionTags = tags
+	meta.TransitiveTagKeys = append(meta.TransitiveTagKeys, hop.TransitiveTagKeys...)
+
+	if result.AssumedRoleUser != nil {
+		meta.AssumedRoleARN = aws.StringValue(result.AssumedRoleUser.Arn)
+		meta.AssumedRoleID = aws.StringValue(result.AssumedRoleUser.AssumedRoleId)
+		meta.AccountID = accountIDFromARN(meta.AssumedRoleARN)
+		if idx := strings.LastIndex(meta.AssumedRoleARN, "/"); idx != -1 {
+			meta.RoleSessionName = meta.AssumedRoleARN[idx+1:]
+		}
+	}
+	if result.SourceIdentity != nil {
+		meta.SourceIdentity = aws.StringValue(result.SourceIdentity)
+	}
+	if result.Credentials != nil && result.Credentials.Expiration != nil {
+		meta.Expiration = result.Credentials.Expiration.Format(time.RFC3339)
+	}
+}
+
+func promptMFATokenCode(serial string) (string, error) {
+	fmt.Fprintf(os.Stderr, "MFA token code for %s: ", serial)
+	var code string
+	fmt.Scanln(&code)
+	if code == "" {
+		return "", fmt.Errorf("MFA token code is required for %s", serial)
+	}
+	return code, nil
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// RoleListing is one role/principal pair from a SAML assertion.
+type RoleListing struct {
+	AccountID    string `json:"account_id" yaml:"account_id"`
+	RoleName     string `json:"role_name" yaml:"role_name"`
+	RoleARN      string `json:"role_arn" yaml:"role_arn"`
+	PrincipalARN string `json:"principal_arn" yaml:"principal_arn"`
+	App          string `json:"app,omitempty" yaml:"app,omitempty"`
+}
+
+func (a *Authenticator) ListRoles(format string) error {
+	if a.resolveAuthFlow() == "oidc" {
+		// Listing is read-only, so every discovered app is included rather
+		// than asking which ones to use.
+		if a.config.AWSAcctFedAppID == "" && len(a.config.AWSAcctFedAppIDs) == 0 {
+			a.config.AllApps = true
+		}
+		assertions, err := a.samlAssertionsFromOIDC()
+		if err != nil {
+			return err
+		}
+		roles, _, err := a.mergeAppRoles(assertions)
+		if err != nil {
+			return err
+		}
+		return printRoleListings(newRoleListings(roles), format)
+	}
+
+	samlAssertion, err := a.FetchSAMLAssertion()
+	if err != nil {
+		return err
+	}
+
+	roles, err := a.extractRolesFromSAML(samlAssertion)
+	if err != nil {
+		return fmt.Errorf("failed to extract roles from SAML: %w", err)
+	}
+
+	return printRoleListings(newRoleListings(roles), format)
+}
+
+func newRoleListings(roles []awsRole) []RoleListing {
+	listings := make([]RoleListing, 0, len(roles))
+	for _, role := range roles {
+		listings = append(listings, RoleListing{
+			AccountID:    accountIDFromARN(role.RoleARN),
+			RoleName:     roleNameFromARN(role.RoleARN),
+			RoleARN:      role.RoleARN,
+			PrincipalARN: role.PrincipalARN,
+			App:          role.App,
+		})
+	}
+	return listings
+}
+
+func printRoleListings(listings []RoleListing, format string) error {
+	return printStructured(format, listings, func() error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if len(listings) > 0 && listings[0].App != "" {
+			fmt.Fprintln(w, "APP\tACCOUNT\tROLE\tROLE ARN\tPRINCIPAL ARN")
+			for _, l := range listings {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", l.App, l.AccountID, l.RoleName, l.RoleARN, l.PrincipalARN)
+			}
+			return w.Flush()
+		}
+		fmt.Fprintln(w, "ACCOUNT\tROLE\tROLE ARN\tPRINCIPAL ARN")
+		for _, l := range listings {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", l.AccountID, l.RoleName, l.RoleARN, l.PrincipalARN)
+		}
+		return w.Flush()
+	})
+}
+
+func accountIDFromARN(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[4]
+}
+
+func roleNameFromARN(arn string) string {
+	idx := strings.Index(arn, ":role/")
+	if idx == -1 {
+		return ""
+	}
+	name := arn[idx+len(":role/"):]
+	if slash := strings.LastIndex(name, "/"); slash != -1 {
+		name = name[slash+1:]
+	}
+	return name
+}
@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+const (
+	samlRoleAttribute              = "https://aws.amazon.com/SAML/Attributes/Role"
+	samlRoleSessionNameAttribute   = "https://aws.amazon.com/SAML/Attributes/RoleSessionName"
+	samlSessionDurationAttribute   = "https://aws.amazon.com/SAML/Attributes/SessionDuration"
+	samlSourceIdentityAttribute    = "https://aws.amazon.com/SAML/Attributes/SourceIdentity"
+	samlPrincipalTagAttribute      = "https://aws.amazon.com/SAML/Attributes/PrincipalTag:"
+	samlTransitiveTagKeysAttribute = "https://aws.amazon.com/SAML/Attributes/TransitiveTagKeys"
+)
+
+// samlResponse holds the fields oktaws reads from a SAML Response. The
+// assertion is decoded from the single Assertion element in the document,
+// the same element verifySAMLSignature checks, so nothing outside the signed
+// content can add to or override it.
+type samlResponse struct {
+	ID           string
+	Destination  string
+	IssueInstant string
+	Issuer       string
+	Assertion    parsedAssertion
+
+	root          *etree.Element
+	assertionElem *etree.Element
+}
+
+type parsedAssertion struct {
+	ID           string `xml:"ID,attr"`
+	IssueInstant string `xml:"IssueInstant,attr"`
+	Issuer       string `xml:"Issuer"`
+	Subject      struct {
+		NameID                  string `xml:"NameID"`
+		SubjectConfirmationData struct {
+			NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
+			Recipient    string `xml:"Recipient,attr"`
+		} `xml:"SubjectConfirmation>SubjectConfirmationData"`
+	} `xml:"Subject"`
+	Conditions struct {
+		NotBefore    string   `xml:"NotBefore,attr"`
+		NotOnOrAfter string   `xml:"NotOnOrAfter,attr"`
+		Audiences    []string `xml:"AudienceRestriction>Audience"`
+	} `xml:"Conditions"`
+	AttributeStatement struct {
+		Attributes []samlAttribute `xml:"Attribute"`
+	} `xml:"AttributeStatement"`
+}
+
+type samlAttribute struct {
+	Name           string `xml:"Name,attr"`
+	AttributeValue []struct {
+		Value string `xml:",chardata"`
+	} `xml:"AttributeValue"`
+}
+
+func (attr samlAttribute) values() []string {
+	values := make([]string, 0, len(attr.AttributeValue))
+	for _, v := range attr.AttributeValue {
+		values = append(values, strings.TrimSpace(v.Value))
+	}
+	return values
+}
+
+type samlSessionAttributes struct {
+	RoleSessionName   string            `json:"role_session_name,omitempty" yaml:"role_session_name,omitempty"`
+	SourceIdentity    string            `json:"source_identity,omitempty" yaml:"source_identity,omitempty"`
+	SessionDuration   int               `json:"session_duration,omitempty" yaml:"session_duration,omitempty"`
+	PrincipalTags     map[string]string `json:"principal_tags,omitempty" yaml:"principal_tags,omitempty"`
+	TransitiveTagKeys []string          `json:"transitive_tag_keys,omitempty" yaml:"transitive_tag_keys,omitempty"`
+}
+
+type awsRole struct {
+	RoleARN      string
+	PrincipalARN string
+	App          string
+}
+
+func decodeSAMLAssertion(samlAssertion string) ([]byte, error) {
+	decodedSAML, err := base64.StdEncoding.DecodeString(strings.TrimSpace(samlAssertion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SAML: %w", err)
+	}
+	return decodedSAML, nil
+}
+
+func parseSAMLResponse(samlAssertion string) (*samlResponse, error) {
+	decodedSAML, err := decodeSAMLAssertion(samlAssertion)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(decodedSAML); err != nil {
+		return nil, fmt.Errorf("failed to parse SAML XML: %w", err)
+	}
+	root := doc.Root()
+	if root == nil || root.Tag != "Response" || root.NamespaceURI() != samlProtocolNamespace {
+		return nil, fmt.Errorf("document is not a SAML Response")
+	}
+
+	// Any other element named Assertion, whatever its namespace, is a sign
+	// of signature wrapping: a parser could read it instead of the signed one.
+	assertions := root.FindElements("//Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("expected exactly one assertion, found %d", len(assertions))
+	}
+	assertionElem := assertions[0]
+	if assertionElem.Parent() != root || assertionElem.NamespaceURI() != samlAssertionNamespace {
+		return nil, fmt.Errorf("assertion is not a SAML assertion in the response")
+	}
+
+	samlResp := &samlResponse{
+		ID:            root.SelectAttrValue("ID", ""),
+		Destination:   root.SelectAttrValue("Destination", ""),
+		IssueInstant:  root.SelectAttrValue("IssueInstant", ""),
+		root:          root,
+		assertionElem: assertionElem,
+	}
+	if issuer := childElement(root, samlAssertionNamespace, "Issuer"); issuer != nil {
+		samlResp.Issuer = strings.TrimSpace(issuer.Text())
+	}
+
+	// Detach the assertion with the namespaces it inherits from the response,
+	// so it decodes on its own exactly as the signature sees it.
+	nsCtx, err := etreeutils.NSBuildParentContext(assertionElem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SAML assertion: %w", err)
+	}
+	detached, err := etreeutils.NSDetatch(nsCtx, assertionElem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SAML assertion: %w", err)
+	}
+	assertionDoc := etree.NewDocument()
+	assertionDoc.SetRoot(detached)
+	assertionXML, err := assertionDoc.WriteToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SAML assertion: %w", err)
+	}
+	if err := xml.Unmarshal(assertionXML, &samlResp.Assertion); err != nil {
+		return nil, fmt.Errorf("failed to parse SAML assertion: %w", err)
+	}
+
+	return samlResp, nil
+}
+
+// childElement returns the first direct child of e with the given namespace
+// and local name.
+func childElement(e *etree.Element, namespace, local string) *etree.Element {
+	for _, child := range e.ChildElements() {
+		if child.Tag == local && child.NamespaceURI() == namespace {
+			return child
+		}
+	}
+	return nil
+}
+
+func (r *samlResponse) attributes() []samlAttribute {
+	return r.Assertion.AttributeStatement.Attributes
+}
+
+func (r *samlResponse) sessionAttributes() samlSessionAttributes {
+	var attrs samlSessionAttributes
+	for _, attr := range r.attributes() {
+		values := attr.values()
+		if len(values) == 0 {
+			continue
+		}
+		switch {
+		case attr.Name == samlRoleSessionNameAttribute:
+			attrs.RoleSessionName = values[0]
+		case attr.Name == samlSourceIdentityAttribute:
+			attrs.SourceIdentity = values[0]
+		case attr.Name == samlSessionDurationAttribute:
+			if duration, err := strconv.Atoi(values[0]); err == nil {
+				attrs.SessionDuration = duration
+			}
+		case strings.HasPrefix(attr.Name, samlPrincipalTagAttribute):
+			if attrs.PrincipalTags == nil {
+				attrs.PrincipalTags = make(map[string]string)
+			}
+			attrs.PrincipalTags[strings.TrimPrefix(attr.Name, samlPrincipalTagAttribute)] = values[0]
+		case attr.Name == samlTransitiveTagKeysAttribute:
+			attrs.TransitiveTagKeys = append(attrs.TransitiveTagKeys, values...)
+		}
+	}
+	return attrs
+}
+
+func (r *samlResponse) roles() []awsRole {
+	var roles []awsRole
+	for _, attr := range r.attributes() {
+		if attr.Name != samlRoleAttribute {
+			continue
+		}
+		for _, value := range attr.values() {
+			parts := strings.Split(value, ",")
+			if len(parts) == 2 {
+				var role awsRole
+				if strings.Contains(parts[0], ":role/") {
+					role.RoleARN = parts[0]
+					role.PrincipalARN = parts[1]
+				} else {
+					role.RoleARN = parts[1]
+					role.PrincipalARN = parts[0]
+				}
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+func (a *Authenticator) extractRolesFromSAML(samlAssertion string) ([]awsRole, error) {
+	samlResp, err := parseSAMLResponse(samlAssertion)
+	if err != nil {
+		return nil, err
+	}
+
+	a.samlAttributes = samlResp.sessionAttributes()
+
+	roles := samlResp.roles()
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no IAM roles found in SAML assertion")
+	}
+
+	return roles, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+type samlForm struct {
+	Action       string
+	SAMLResponse string
+	RelayState   string
+}
+
+type htmlTag struct {
+	name  string
+	attrs map[string]string
+}
+
+var oktaInterstitials = []struct {
+	markers []string
+	message string
+}{
+	{
+		markers: []string{"password has expired", "password-expired", "password_expired", "change your password"},
+		message: "your Okta password has expired; sign in to Okta in a browser to change it, then retry",
+	},
+	{
+		markers: []string{"/signin/verify", "step-up", "stepup", "additional verification", "extra verification"},
+		message: "Okta requires additional MFA verification (step-up) for this app; run with --auth-flow saml-browser to complete it in the browser, then retry",
+	},
+	{
+		markers: []string{"sign-on policy", "signon policy", "do not have permission", "not assigned to", "access denied"},
+		message: "Okta's sign-on policy denied access to this app; ask your Okta administrator to check your app assignment and the app sign-on policy",
+	},
+	{
+		markers: []string{"okta-sign-in", "signin-container", "name=\"password\""},
+		message: "Okta returned its sign-in page instead of a SAML form; the session is not authorized for this app, so sign in again",
+	},
+}
+
+func extractSAMLFromHTML(htmlContent string) (string, error) {
+	form, err := parseSAMLForm(htmlContent)
+	if err != nil {
+		return "", err
+	}
+	return form.SAMLResponse, nil
+}
+
+func parseSAMLForm(htmlContent string) (*samlForm, error) {
+	var form samlForm
+	var currentAction string
+	for _, tag := range parseHTMLTags(htmlContent) {
+		switch tag.name {
+		case "form":
+			currentAction = tag.attrs["action"]
+		case "input":
+			switch strings.ToLower(tag.attrs["name"]) {
+			case "samlresponse":
+				form.SAMLResponse = strings.Join(strings.Fields(tag.attrs["value"]), "")
+				form.Action = currentAction
+			case "relaystate":
+				form.RelayState = tag.attrs["value"]
+			}
+		}
+	}
+
+	if form.SAMLResponse == "" {
+		return nil, describeMissingSAMLForm(htmlContent)
+	}
+	return &form, nil
+}
+
+func describeMissingSAMLForm(htmlContent string) error {
+	lower := strings.ToLower(htmlContent)
+	for _, interstitial := range oktaInterstitials {
+		for _, marker := range interstitial.markers {
+			if strings.Contains(lower, marker) {
+				return fmt.Errorf("SAMLResponse not found in HTML: %s", interstitial.message)
+			}
+		}
+	}
+	return fmt.Errorf("SAMLResponse not found in HTML")
+}
+
+// parseHTMLTags returns the start tags in content with decoded attribute
+// values. It skips comments and the bodies of script and style elements.
+func parseHTMLTags(content string) []htmlTag {
+	var tags []htmlTag
+	for i := 0; i < len(content); {
+		lt := strings.IndexByte(content[i:], '<')
+		if lt == -1 {
+			break
+		}
+		i += lt + 1
+		if strings.HasPrefix(content[i:], "!--") {
+			end := strings.Index(content[i:], "-->")
+			if end == -1 {
+				break
+			}
+			i += end + 3
+			continue
+		}
+		if i >= len(content) || !isHTMLNameStart(content[i]) {
+			continue
+		}
+
+		tag, next := parseHTMLTag(content, i)
+		tags = append(tags, tag)
+		i = next
+
+		if tag.name == "script" || tag.name == "style" {
+			end := strings.Index(strings.ToLower(content[i:]), "</"+tag.name)
+			if end == -1 {
+				break
+			}
+			i += end
+		}
+	}
+	return tags
+}
+
+func parseHTMLTag(content string, i int) (htmlTag, int) {
+	start := i
+	for i < len(content) && !isHTMLSpace(content[i]) && content[i] != '>' && content[i] != '/' {
+		i++
+	}
+	tag := htmlTag{name: strings.ToLower(content[start:i]), attrs: map[string]string{}}
+
+	for i < len(content) {
+		for i < len(content) && (isHTMLSpace(content[i]) || content[i] == '/') {
+			i++
+		}
+		if i >= len(content) {
+			break
+		}
+		if content[i] == '>' {
+			return tag, i + 1
+		}
+
+		nameStart := i
+		for i < len(content) && !isHTMLSpace(content[i]) && content[i] != '=' && content[i] != '>' && content[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(content[nameStart:i])
+
+		for i < len(content) && isHTMLSpace(content[i]) {
+			i++
+		}
+		if i >= len(content) || content[i] != '=' {
+			tag.attrs[name] = ""
+			continue
+		}
+		i++
+		for i < len(content) && isHTMLSpace(content[i]) {
+			i++
+		}
+
+		var value string
+		if i < len(content) && (content[i] == '"' || content[i] == '\'') {
+			quote := content[i]
+			end := strings.IndexByte(content[i+1:], quote)
+			if end == -1 {
+				value = content[i+1:]
+				i = len(content)
+			} else {
+				value = content[i+1 : i+1+end]
+				i += end + 2
+			}
+		} else {
+			valueStart := i
+			for i < len(content) && !isHTMLSpace(content[i]) && content[i] != '>' {
+				i++
+			}
+			value = content[valueStart:i]
+		}
+		if _, exists := tag.attrs[name]; !exists {
+			tag.attrs[name] = html.UnescapeString(value)
+		}
+	}
+	return tag, i
+}
+
+func isHTMLNameStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func (a *Authenticator) AuthenticateWithSAMLInput() error {
+	samlAssertion, err := a.samlAssertionFromInput()
+	if err != nil {
+		return err
+	}
+
+	roles, err := a.extractRolesFromSAML(samlAssertion)
+	if err != nil {
+		return fmt.Errorf("failed to extract roles from SAML: %w", err)
+	}
+
+	roleARN, principalARN, err := a.selectRole(roles)
+	if err != nil {
+		return fmt.Errorf("failed to select role: %w", err)
+	}
+
+	creds, err := a.assumeRole(samlAssertion, roleARN, principalARN)
+	if err != nil {
+		return fmt.Errorf("failed to assume role: %w", err)
+	}
+
+	log.Printf("Assumed role: %s", roleARN)
+	if err := a.outputCredentials(creds); err != nil {
+		return err
+	}
+	return a.writeReadOnlyProfile(samlAssertion, roleARN, principalARN, creds)
+}
+
+func (a *Authenticator) samlAssertionFromInput() (string, error) {
+	switch {
+	case a.config.SAMLFile != "" && a.config.SAMLFile != "-":
+		f, err := os.Open(a.config.SAMLFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to open SAML file: %w", err)
+		}
+		defer f.Close()
+		return ReadSAMLResponse(f)
+	case a.config.SAMLFile == "-" || !isTerminal(os.Stdin):
+		return ReadSAMLResponse(os.Stdin)
+	default:
+		fmt.Fprintln(os.Stderr, "Paste the SAMLResponse (base64 or the saved HTML page), then press Ctrl-D (Ctrl-Z and Enter on Windows):")
+		return ReadSAMLResponse(os.Stdin)
+	}
+}
+
+func ReadSAMLResponse(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return normalizeSAMLInput(string(data))
+}
+
+func normalizeSAMLInput(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("no SAMLResponse provided")
+	}
+	if strings.Contains(input, "<") {
+		return extractSAMLFromHTML(input)
+	}
+	if strings.HasPrefix(input, "SAMLResponse=") {
+		values, err := url.ParseQuery(input)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse form body: %w", err)
+		}
+		input = values.Get("SAMLResponse")
+	} else if strings.Contains(input, "%") {
+		if unescaped, err := url.QueryUnescape(input); err == nil {
+			input = unescaped
+		}
+	}
+	return strings.Join(strings.Fields(input), ""), nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type samlReport struct {
+	ResponseIssuer   string                `json:"response_issuer,omitempty" yaml:"response_issuer,omitempty"`
+	Issuer           string                `json:"issuer" yaml:"issuer"`
+	Subject          string                `json:"subject" yaml:"subject"`
+	AssertionID      string                `json:"assertion_id" yaml:"assertion_id"`
+	IssueInstant     string                `json:"issue_instant,omitempty" yaml:"issue_instant,omitempty"`
+	NotBefore        string                `json:"not_before,omitempty" yaml:"not_before,omitempty"`
+	NotOnOrAfter     string                `json:"not_on_or_after,omitempty" yaml:"not_on_or_after,omitempty"`
+	Audiences        []string              `json:"audiences" yaml:"audiences"`
+	Destination      string                `json:"destination,omitempty" yaml:"destination,omitempty"`
+	Recipient        string                `json:"recipient,omitempty" yaml:"recipient,omitempty"`
+	Attributes       []samlReportAttribute `json:"attributes" yaml:"attributes"`
+	Roles            []RoleListing         `json:"roles" yaml:"roles"`
+	Signature        string                `json:"signature" yaml:"signature"`
+	Conditions       string                `json:"conditions" yaml:"conditions"`
+	DestinationCheck string                `json:"destination_check" yaml:"destination_check"`
+	ClockSkew        string                `json:"clock_skew" yaml:"clock_skew"`
+}
+
+type samlReportAttribute struct {
+	Name   string   `json:"name" yaml:"name"`
+	Values []string `json:"values" yaml:"values"`
+}
+
+func PrintRawSAML(samlAssertion string, decoded bool) error {
+	if !decoded {
+		fmt.Println(samlAssertion)
+		return nil
+	}
+	decodedSAML, err := decodeSAMLAssertion(samlAssertion)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(decodedSAML, '\n'))
+	return err
+}
+
+func (a *Authenticator) InspectSAML(samlAssertion, format string) error {
+	report, err := a.buildSAMLReport(samlAssertion, time.Now())
+	if err != nil {
+		return err
+	}
+	return printSAMLReport(report, format)
+}
+
+func (a *Authenticator) buildSAMLReport(samlAssertion string, now time.Time) (*samlReport, error) {
+	samlResp, err := parseSAMLResponse(samlAssertion)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion := samlResp.Assertion
+	report := &samlReport{
+		ResponseIssuer:   strings.TrimSpace(samlResp.Issuer),
+		Issuer:           strings.TrimSpace(assertion.Issuer),
+		Subject:          strings.TrimSpace(assertion.Subject.NameID),
+		AssertionID:      assertion.ID,
+		IssueInstant:     assertion.IssueInstant,
+		NotBefore:        assertion.Conditions.NotBefore,
+		NotOnOrAfter:     assertion.Conditions.NotOnOrAfter,
+		Audiences:        assertion.Conditions.Audiences,
+		Destination:      samlResp.Destination,
+		Recipient:        assertion.Subject.SubjectConfirmationData.Recipient,
+		Conditions:       "ok",
+		DestinationCheck: "ok",
+	}
+	for _, attr := range samlResp.attributes() {
+		report.Attributes = append(report.Attributes, samlReportAttribute{Name: attr.Name, Values: attr.values()})
+	}
+	report.Roles = newRoleListings(samlResp.roles())
+
+	report.Signature = "valid"
+	if certs, err := a.idpCertificates(a.issuingApp(samlAssertion)); err != nil {
+		report.Signature = fmt.Sprintf("not checked: %v", err)
+	} else if err := verifySAMLSignature(samlResp, certs); err != nil {
+		report.Signature = fmt.Sprintf("invalid: %v", err)
+	}
+	if err := checkSAMLConditions(samlResp, now); err != nil {
+		report.Conditions = err.Error()
+	}
+	if err := checkSAMLDestination(samlResp); err != nil {
+		report.DestinationCheck = err.Error()
+	}
+	report.ClockSkew = describeClockSkew(samlResp, now)
+
+	return report, nil
+}
+
+func describeClockSkew(samlResp *samlResponse, now time.Time) string {
+	conditions := samlResp.Assertion.Conditions
+	var notes []string
+	if issued, err := time.Parse(time.RFC3339Nano, samlResp.Assertion.IssueInstant); err == nil {
+		notes = append(notes, fmt.Sprintf("issued %s ago by Okta's clock", now.Sub(issued).Round(time.Second)))
+	}
+	if notBefore, err := time.Parse(time.RFC3339Nano, conditions.NotBefore); err == nil && now.Before(notBefore) {
+		ahead := notBefore.Sub(now).Round(time.Second)
+		if ahead > samlClockSkew {
+			notes = append(notes, fmt.Sprintf("not valid for another %s: STS will reject it (local clock is behind?)", ahead))
+		} else {
+			notes = append(notes, fmt.Sprintf("not valid for another %s: within the %s tolerance", ahead, samlClockSkew))
+		}
+	}
+	if notOnOrAfter, err := time.Parse(time.RFC3339Nano, conditions.NotOnOrAfter); err == nil {
+		if remaining := notOnOrAfter.Sub(now).Round(time.Second); remaining > 0 {
+			notes = append(notes, fmt.Sprintf("expires in %s", remaining))
+		} else if -remaining > samlClockSkew {
+			notes = append(notes, fmt.Sprintf("expired %s ago: STS will reject it", -remaining))
+		} else {
+			notes = append(notes, fmt.Sprintf("expired %s ago: STS may still accept it within its skew tolerance", -remaining))
+		}
+	}
+	if len(notes) == 0 {
+		return "no validity window in assertion"
+	}
+	return strings.Join(notes, "; ")
+}
+
+func printSAMLReport(report *samlReport, format string) error {
+	return printStructured(format, report, func() error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Issuer:\t%s\n", report.Issuer)
+		fmt.Fprintf(w, "Subject:\t%s\n", report.Subject)
+		fmt.Fprintf(w, "Assertion ID:\t%s\n", report.AssertionID)
+		fmt.Fprintf(w, "Issued at:\t%s\n", report.IssueInstant)
+		fmt.Fprintf(w, "Valid from:\t%s\n", report.NotBefore)
+		fmt.Fprintf(w, "Valid until:\t%s\n", report.NotOnOrAfter)
+		fmt.Fprintf(w, "Audience:\t%s\n", strings.Join(report.Audiences, ", "))
+		fmt.Fprintf(w, "Destination:\t%s\n", report.Destination)
+		fmt.Fprintf(w, "Recipient:\t%s\n", report.Recipient)
+		fmt.Fprintf(w, "Signature:\t%s\n", report.Signature)
+		fmt.Fprintf(w, "Conditions:\t%s\n", report.Conditions)
+		fmt.Fprintf(w, "Destination check:\t%s\n", report.DestinationCheck)
+		fmt.Fprintf(w, "Clock skew:\t%s\n", report.ClockSkew)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Println("\nAttributes:")
+		for _, attr := range report.Attributes {
+			fmt.Printf("  %s\n", attr.Name)
+			for _, value := range attr.Values {
+				fmt.Printf("    %s\n", value)
+			}
+		}
+
+		fmt.Println("\nRoles:")
+		if len(report.Roles) == 0 {
+			fmt.Println("  (none)")
+			return nil
+		}
+		return printRoleListings(report.Roles, "table")
+	})
+}
@@ -0,0 +1,300 @@
+package internal
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+const (
+	samlProtocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlClockSkew          = 5 * time.Minute
+)
+
+var awsSAMLAudiences = []string{
+	"urn:amazon:webservices",
+	"urn:amazon:webservices:govcloud",
+	"urn:amazon:webservices:cn",
+}
+
+var awsSignInHosts = []string{
+	"signin.aws.amazon.com",
+	"signin.amazonaws-us-gov.com",
+	"signin.amazonaws.cn",
+}
+
+func (a *Authenticator) validateSAMLAssertion(samlAssertion string) error {
+	if a.config.SkipSAMLValidation {
+		if a.config.Debug {
+			fmt.Fprintf(os.Stderr, "Warning: skipping local SAML validation\n")
+		}
+		return nil
+	}
+
+	samlResp, err := parseSAMLResponse(samlAssertion)
+	if err != nil {
+		return err
+	}
+
+	certs, err := a.idpCertificates(a.issuingApp(samlAssertion))
+	if err != nil {
+		return fmt.Errorf("failed to load IdP certificate: %w (set saml_idp_cert or --skip-saml-validation)", err)
+	}
+	if err := verifySAMLSignature(samlResp, certs); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+	if err := checkSAMLConditions(samlResp, time.Now()); err != nil {
+		return err
+	}
+	if err := checkSAMLDestination(samlResp); err != nil {
+		return err
+	}
+	if err := recordAssertionID(samlResp.Assertion.ID, assertionExpiry(samlResp)); err != nil {
+		return err
+	}
+
+	if a.config.Debug {
+		fmt.Fprintf(os.Stderr, "✓ SAML assertion %s validated locally\n", samlResp.Assertion.ID)
+	}
+	return nil
+}
+
+// verifySAMLSignature checks the signature over the assertion that samlResp
+// was decoded from, or over the whole response when that is what is signed.
+func verifySAMLSignature(samlResp *samlResponse, certs []*x509.Certificate) error {
+	signed := samlResp.assertionElem
+	if childElement(signed, dsig.Namespace, dsig.SignatureTag) == nil {
+		signed = samlResp.root
+		if childElement(signed, dsig.Namespace, dsig.SignatureTag) == nil {
+			return fmt.Errorf("neither the response nor the assertion is signed")
+		}
+	}
+
+	nsCtx, err := etreeutils.NSBuildParentContext(signed)
+	if err != nil {
+		return err
+	}
+	detached, err := etreeutils.NSDetatch(nsCtx, signed)
+	if err != nil {
+		return err
+	}
+	validator := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: certs})
+	_, err = validator.Validate(detached)
+	return err
+}
+
+func checkSAMLConditions(samlResp *samlResponse, now time.Time) error {
+	conditions := samlResp.Assertion.Conditions
+	if conditions.NotBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339Nano, conditions.NotBefore)
+		if err != nil {
+			return fmt.Errorf("invalid NotBefore %q: %w", conditions.NotBefore, err)
+		}
+		if now.Add(samlClockSkew).Before(notBefore) {
+			return fmt.Errorf("assertion is not valid until %s (check your system clock)", notBefore.Format(time.RFC3339))
+		}
+	}
+	for _, notOnOrAfterStr := range []string{conditions.NotOnOrAfter, samlResp.Assertion.Subject.SubjectConfirmationData.NotOnOrAfter} {
+		if notOnOrAfterStr == "" {
+			continue
+		}
+		notOnOrAfter, err := time.Parse(time.RFC3339Nano, notOnOrAfterStr)
+		if err != nil {
+			return fmt.Errorf("invalid NotOnOrAfter %q: %w", notOnOrAfterStr, err)
+		}
+		if !now.Add(-samlClockSkew).Before(notOnOrAfter) {
+			return fmt.Errorf("assertion expired at %s", notOnOrAfter.Format(time.RFC3339))
+		}
+	}
+
+	if len(conditions.Audiences) == 0 {
+		return fmt.Errorf("assertion has no audience restriction")
+	}
+	for _, audience := range conditions.Audiences {
+		for _, expected := range awsSAMLAudiences {
+			if strings.TrimSpace(audience) == expected {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("assertion audience %v is not AWS", conditions.Audiences)
+}
+
+func checkSAMLDestination(samlResp *samlResponse) error {
+	for _, destination := range []string{samlResp.Destination, samlResp.Assertion.Subject.SubjectConfirmationData.Recipient} {
+		if destination == "" {
+			continue
+		}
+		if !isAWSSignInURL(destination) {
+			return fmt.Errorf("assertion destination %s is not an AWS sign-in endpoint", destination)
+		}
+	}
+	return nil
+}
+
+func isAWSSignInURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || strings.TrimSuffix(u.Path, "/") != "/saml" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, signInHost := range awsSignInHosts {
+		if host == signInHost || strings.HasSuffix(host, "."+signInHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// issuingApp returns the app a SAML assertion came from: the one recorded when
+// it was fetched from several apps, or else the configured app.
+func (a *Authenticator) issuingApp(samlAssertion string) string {
+	if appID := a.assertionApps[samlAssertion]; appID != "" {
+		return appID
+	}
+	return a.config.AWSAcctFedAppID
+}
+
+func (a *Authenticator) idpCertificates(appID string) ([]*x509.Certificate, error) {
+	if a.config.SAMLIdPCert != "" {
+		data, err := os.ReadFile(a.config.SAMLIdPCert)
+		if err != nil {
+			return nil, err
+		}
+		return parsePEMCertificates(data)
+	}
+	if a.config.OrgDomain == "" || appID == "" {
+		return nil, fmt.Errorf("org-domain and aws-acct-fed-app-id are required to fetch IdP metadata")
+	}
+	return a.fetchIdPMetadataCertificates(appID)
+}
+
+func (a *Authenticator) fetchIdPMetadataCertificates(appID string) ([]*x509.Certificate, error) {
+	metadataURL := fmt.Sprintf("https://%s/app/%s/sso/saml/metadata", a.config.OrgDomain, appID)
+
+	if a.config.DebugAPICalls {
+		fmt.Fprintf(os.Stderr, "GET %s\n", metadataURL)
+	}
+
+	resp, err := a.httpClient.Get(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("IdP metadata request failed with status %d", resp.StatusCode)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(body); err != nil {
+		return nil, fmt.Errorf("failed to parse IdP metadata: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for _, keyDescriptor := range doc.FindElements("//KeyDescriptor") {
+		if keyDescriptor.SelectAttrValue("use", "") == "encryption" {
+			continue
+		}
+		for _, certElem := range keyDescriptor.FindElements(".//X509Certificate") {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(certElem.Text()), ""))
+			if err != nil {
+				continue
+			}
+			if cert, err := x509.ParseCertificate(der); err == nil {
+				certs = append(certs, cert)
+			}
+		}
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no signing certificate found in IdP metadata")
+	}
+	return certs, nil
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+func assertionExpiry(samlResp *samlResponse) time.Time {
+	for _, value := range []string{samlResp.Assertion.Conditions.NotOnOrAfter, samlResp.Assertion.Subject.SubjectConfirmationData.NotOnOrAfter} {
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t.Add(samlClockSkew)
+		}
+	}
+	return time.Now().Add(time.Hour)
+}
+
+func replayCachePath() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), "saml_replay_cache.json")
+}
+
+func recordAssertionID(id string, expiry time.Time) error {
+	if id == "" {
+		return fmt.Errorf("assertion has no ID")
+	}
+
+	cachePath := replayCachePath()
+	seen := map[string]int64{}
+	if data, err := os.ReadFile(cachePath); err == nil {
+		if err := json.Unmarshal(data, &seen); err != nil {
+			log.Printf("Warning: ignoring corrupt SAML replay cache %s", cachePath)
+			seen = map[string]int64{}
+		}
+	}
+
+	now := time.Now().Unix()
+	for seenID, expiresAt := range seen {
+		if expiresAt < now {
+			delete(seen, seenID)
+		}
+	}
+	if _, ok := seen[id]; ok {
+		return fmt.Errorf("assertion %s has already been used (possible replay)", id)
+	}
+	seen[id] = expiry.Unix()
+
+	data, err := json.MarshalIndent(seen, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, data, 0600)
+}
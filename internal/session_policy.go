@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const readOnlyPolicyName = "ReadOnlyAccess"
+
+// sessionPolicy is the downscoping attached to a single assume call.
+type sessionPolicy struct {
+	arns   []string
+	inline string
+}
+
+// configuredSessionPolicy reads session_policy_arns and session_policy_file.
+func (a *Authenticator) configuredSessionPolicy() (sessionPolicy, error) {
+	policy := sessionPolicy{arns: a.config.SessionPolicyARNs}
+	if a.config.SessionPolicyFile != "" {
+		inline, err := loadSessionPolicy(a.config.SessionPolicyFile)
+		if err != nil {
+			return sessionPolicy{}, err
+		}
+		policy.inline = inline
+	}
+	return policy, nil
+}
+
+func (p sessionPolicy) withARN(arn string) sessionPolicy {
+	p.arns = append(append([]string{}, p.arns...), arn)
+	return p
+}
+
+func (p sessionPolicy) document() *string {
+	if p.inline == "" {
+		return nil
+	}
+	return aws.String(p.inline)
+}
+
+func loadSessionPolicy(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read session policy: %w", err)
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, data); err != nil {
+		return "", fmt.Errorf("session policy %s is not valid JSON: %w", path, err)
+	}
+	return compacted.String(), nil
+}
+
+func policyDescriptors(arns []string) []*sts.PolicyDescriptorType {
+	var descriptors []*sts.PolicyDescriptorType
+	for _, arn := range arns {
+		descriptors = append(descriptors, &sts.PolicyDescriptorType{Arn: aws.String(arn)})
+	}
+	return descriptors
+}
+
+func readOnlyPolicyARN(roleARN string) string {
+	partition := partitionFromARN(roleARN)
+	if partition == "" {
+		partition = "aws"
+	}
+	return fmt.Sprintf("arn:%s:iam::aws:policy/%s", partition, readOnlyPolicyName)
+}
+
+// writeReadOnlyProfile writes a companion "<profile>-readonly" profile
+// limited by the ReadOnlyAccess managed policy on top of any configured
+// session policy. Without a role chain it re-uses the assertion. With one,
+// replaying the chain would need a fresh MFA code (AWS rejects a code twice),
+// so the last role assumes itself from the chained credentials instead; its
+// trust policy must allow that.
+func (a *Authenticator) writeReadOnlyProfile(samlAssertion, roleARN, principalARN string, chainedCreds *sts.Credentials) error {
+	if !a.config.ReadOnlyProfile {
+		return nil
+	}
+
+	policy, err := a.configuredSessionPolicy()
+	if err != nil {
+		return err
+	}
+
+	var creds *sts.Credentials
+	if len(a.config.RoleChain) > 0 {
+		last := a.config.RoleChain[len(a.config.RoleChain)-1]
+		creds, err = a.assumeChainHop(chainedCreds, RoleChainHop{
+			RoleARN:         last.RoleARN,
+			RoleSessionName: last.RoleSessionName,
+			ExternalID:      last.ExternalID,
+			Duration:        last.Duration,
+		}, policy.withARN(readOnlyPolicyARN(last.RoleARN)))
+	} else {
+		creds, err = a.assumeRoleWithSAMLPolicy(samlAssertion, roleARN, principalARN, policy.withARN(readOnlyPolicyARN(roleARN)))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to assume read-only session: %w", err)
+	}
+
+	profile := a.config.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	profile += "-readonly"
+	if err := a.writeCredentialsFile(creds, profile); err != nil {
+		return fmt.Errorf("failed to write read-only credentials: %w", err)
+	}
+	log.Printf("Read-only credentials written to profile: %s", profile)
+	return nil
+}
@@ -0,0 +1,41 @@
+package internal
+
+import "fmt"
+
+// SetupSession is an Okta sign-in made by the setup wizard to look up the
+// user's AWS apps and roles before there is a working configuration.
+type SetupSession struct {
+	auth        *Authenticator
+	accessToken string
+}
+
+// StartSetupSession signs in with the OIDC device flow against the org and
+// client ID in cfg.
+func StartSetupSession(cfg *Config) (*SetupSession, error) {
+	if cfg.OrgDomain == "" || cfg.OIDCClientID == "" {
+		return nil, fmt.Errorf("org_domain and oidc_client_id are needed to sign in")
+	}
+	auth := NewAuthenticator(cfg)
+	accessToken, err := auth.oidcAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	return &SetupSession{auth: auth, accessToken: accessToken}, nil
+}
+
+func (s *SetupSession) AWSApps() ([]AWSApp, error) {
+	return s.auth.listAWSApps(s.accessToken)
+}
+
+// Roles fetches a SAML assertion for the app and lists the roles in it.
+func (s *SetupSession) Roles(appID string) ([]RoleListing, error) {
+	samlAssertion, err := s.auth.getSAMLAssertion(s.accessToken, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SAML assertion: %w", err)
+	}
+	roles, err := s.auth.extractRolesFromSAML(samlAssertion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract roles from SAML: %w", err)
+	}
+	return newRoleListings(roles), nil
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func (a *Authenticator) newSTSSession(roleARN, principalARN string, creds *sts.Credentials) (*session.Session, error) {
+	region, err := a.stsRegion(roleARN, principalARN)
+	if err != nil {
+		return nil, err
+	}
+
+	awsConfig := &aws.Config{
+		Region: aws.String(region),
+	}
+
+	switch a.config.STSEndpointMode {
+	case "regional":
+		awsConfig.STSRegionalEndpoint = endpoints.RegionalSTSEndpoint
+	case "global":
+		awsConfig.STSRegionalEndpoint = endpoints.LegacySTSEndpoint
+	case "":
+	default:
+		return nil, fmt.Errorf("invalid sts_endpoint_mode %q: must be 'global' or 'regional'", a.config.STSEndpointMode)
+	}
+
+	if creds != nil {
+		awsConfig.Credentials = credentials.NewStaticCredentials(
+			aws.StringValue(creds.AccessKeyId),
+			aws.StringValue(creds.SecretAccessKey),
+			aws.StringValue(creds.SessionToken),
+		)
+	}
+
+	if a.config.UseFIPSEndpoint {
+		awsConfig.UseFIPSEndpoint = endpoints.FIPSEndpointStateEnabled
+	}
+
+	if a.config.STSEndpoint != "" {
+		if err := validateEndpointURL(a.config.STSEndpoint); err != nil {
+			return nil, err
+		}
+		awsConfig.Endpoint = aws.String(a.config.STSEndpoint)
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	if a.config.Debug {
+		clientConfig := sess.ClientConfig("sts")
+		fmt.Fprintf(os.Stderr, "Using STS endpoint %s (signing region %s)\n", clientConfig.Endpoint, clientConfig.SigningRegion)
+	}
+
+	return sess, nil
+}
+
+func validateEndpointURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid sts_endpoint %q: must be an http(s) URL", rawURL)
+	}
+	return nil
+}
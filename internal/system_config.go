@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SystemConfig is the admin-managed layer beneath the user's config.yaml.
+// Besides default values for any config key, it can lock keys to those
+// values and restrict the auth flows and session length users may pick.
+type SystemConfig struct {
+	Config             `yaml:",inline"`
+	Locked             []string `yaml:"locked,omitempty"`
+	AllowedAuthFlows   []string `yaml:"allowed_auth_flows,omitempty"`
+	MaxSessionDuration int      `yaml:"max_session_duration,omitempty"`
+
+	path string
+	root yaml.Node
+}
+
+var systemPolicyKeys = []string{"locked", "allowed_auth_flows", "max_session_duration"}
+
+func SystemConfigPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "oktaws", "config.yaml")
+	}
+	return filepath.Join("/etc", "oktaws", "config.yaml")
+}
+
+// LoadSystemConfig reads the system config, or returns nil when there is
+// none. An invalid file is an error rather than being skipped, so a typo
+// cannot quietly lift the locks.
+func LoadSystemConfig() (*SystemConfig, error) {
+	path := SystemConfigPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read system config: %w", err)
+	}
+	if problems := ValidateSystemConfig(data); len(problems) > 0 {
+		var messages []string
+		for _, problem := range problems {
+			messages = append(messages, problem.String())
+		}
+		return nil, fmt.Errorf("invalid system config %s:\n  %s", path, strings.Join(messages, "\n  "))
+	}
+	system := &SystemConfig{path: path}
+	if err := yaml.Unmarshal(data, system); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &system.root); err != nil {
+		return nil, err
+	}
+	return system, nil
+}
+
+// ValidateSystemConfig checks the system config: config keys as in
+// ValidateConfig, plus the policy keys and that every locked key has a value.
+func ValidateSystemConfig(data []byte) []ConfigProblem {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return ValidateConfig(data)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	v := &configValidator{}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.report(root, "", "system config must be a mapping of keys to values")
+		return v.problems
+	}
+	fields := configFields()
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "locked":
+			var locked []string
+			if value.Decode(&locked) != nil {
+				v.report(value, key.Value, "must be a list of config keys")
+				continue
+			}
+			for _, name := range locked {
+				if _, ok := fields[name]; !ok {
+					v.report(value, key.Value, "unknown key %q", name)
+				} else if node := mappingNode(root, name); node == nil || isZeroNode(node) {
+					v.report(value, key.Value, "%s is locked but has no value in the system config", name)
+				}
+			}
+		case "allowed_auth_flows":
+			var flows []string
+			if value.Decode(&flows) != nil || len(flows) == 0 {
+				v.report(value, key.Value, "must be a non-empty list of auth flows")
+				continue
+			}
+			for _, flow := range flows {
+				v.checkOneOf(value, key.Value, flow, validAuthFlows)
+			}
+		case "max_session_duration":
+			var max int
+			if value.Decode(&max) != nil || max < minSessionDuration || max > maxSessionDuration {
+				v.report(value, key.Value, "must be a number of seconds between %d and %d", minSessionDuration, maxSessionDuration)
+			}
+		default:
+			v.checkKey(key, value, "")
+		}
+	}
+	return v.problems
+}
+
+// fill sets every key the user's config file does not set to the system
+// value. A key the file sets wins even when its value is false or empty.
+func (s *SystemConfig) fill(cfg *Config, sources ConfigSources) {
+	for _, key := range nodeKeys(&s.root) {
+		if isSystemPolicyKey(key) || sources[key] != "" {
+			continue
+		}
+		field, err := cfg.configField(key)
+		if err != nil {
+			continue
+		}
+		systemField, _ := s.Config.configField(key)
+		field.Set(systemField)
+		sources.set(key, "system "+s.path)
+	}
+	cfg.MaxSessionDuration = s.MaxSessionDuration
+}
+
+func isSystemPolicyKey(key string) bool {
+	for _, policyKey := range systemPolicyKeys {
+		if key == policyKey {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SystemConfig) isLocked(key string) bool {
+	for _, locked := range s.Locked {
+		if locked == key {
+			return true
+		}
+	}
+	return false
+}
+
+// checkValue rejects a value for key that the system config does not allow.
+func (s *SystemConfig) checkValue(key, value string) error {
+	if s.isLocked(key) {
+		if locked, _ := s.Config.GetValue(key); value != locked {
+			return fmt.Errorf("%s is locked to %q by %s", key, locked, s.path)
+		}
+	}
+	switch key {
+	case "auth_flow":
+		if len(s.AllowedAuthFlows) == 0 || value == "" || value == "auto" {
+			return nil
+		}
+		for _, flow := range s.AllowedAuthFlows {
+			if flow == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("auth flow %s is not allowed by %s (allowed: %s)", value, s.path, strings.Join(s.AllowedAuthFlows, ", "))
+	case "session_duration":
+		if n, _ := strconv.Atoi(value); s.MaxSessionDuration > 0 && n > s.MaxSessionDuration {
+			return fmt.Errorf("session_duration %d exceeds the maximum of %d seconds set by %s", n, s.MaxSessionDuration, s.path)
+		}
+	}
+	return nil
+}
+
+// enforce checks the fully resolved config against the system policy.
+func (s *SystemConfig) enforce(cfg *Config, sources ConfigSources) error {
+	for _, key := range ConfigKeys() {
+		value, _ := cfg.GetValue(key)
+		if err := s.checkValue(key, value); err != nil {
+			return fmt.Errorf("%w (value from %s)", err, sources[key])
+		}
+	}
+	if cfg.AuthFlow == "auto" && len(s.AllowedAuthFlows) > 0 {
+		if err := s.checkValue("auth_flow", cfg.detectAuthFlow()); err != nil {
+			return fmt.Errorf("%w (auto-detected; set auth_flow to one of the allowed flows)", err)
+		}
+	}
+	return nil
+}
+
+// CheckSystemPolicy rejects a value the system config would refuse at load
+// time, so `config set` fails straight away instead of on the next login.
+func CheckSystemPolicy(key, value string) error {
+	system, err := LoadSystemConfig()
+	if err != nil || system == nil {
+		return err
+	}
+	return system.checkValue(normalizeConfigKey(key), value)
+}
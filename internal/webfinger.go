@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// webFingerResponse is the JRD Okta returns from /.well-known/webfinger.
+type webFingerResponse struct {
+	Subject string `json:"subject"`
+	Links   []struct {
+		Rel  string `json:"rel"`
+		Href string `json:"href"`
+	} `json:"links"`
+}
+
+// IsEmailAddress reports whether an org_domain value is really a user's
+// email address to look the org up from.
+func IsEmailAddress(value string) bool {
+	local, domain, ok := strings.Cut(value, "@")
+	return ok && local != "" && strings.Contains(domain, ".")
+}
+
+// DiscoverOrgDomain finds the Okta org, custom domain included, that signs in
+// the given email address by asking seedDomain's WebFinger endpoint. Any Okta
+// domain of the organisation works as the seed; Okta routes the lookup.
+func DiscoverOrgDomain(email, seedDomain string, debugAPICalls bool) (string, error) {
+	if seedDomain == "" {
+		return "", fmt.Errorf("cannot look up the Okta org for %s: set org_discovery_domain (or --org-discovery-domain) to any Okta domain of your organisation, or enter the org domain itself", email)
+	}
+	query := url.Values{}
+	query.Set("resource", "okta:acct:"+email)
+	query.Set("rel", "okta:idp")
+	fingerURL := fmt.Sprintf("https://%s/.well-known/webfinger?%s", seedDomain, query.Encode())
+
+	req, err := http.NewRequest("GET", fingerURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/jrd+json")
+	if debugAPICalls {
+		fmt.Fprintf(os.Stderr, "GET %s\n", fingerURL)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot reach %s to look up the Okta org for %s: %w", seedDomain, email, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if debugAPICalls {
+		fmt.Fprintf(os.Stderr, "Response: %d\n%s\n", resp.StatusCode, string(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s did not answer the Okta org lookup for %s (HTTP %d); check org_discovery_domain is an Okta domain of your organisation", seedDomain, email, resp.StatusCode)
+	}
+
+	var finger webFingerResponse
+	if err := json.Unmarshal(body, &finger); err != nil {
+		return "", fmt.Errorf("%s returned an unexpected WebFinger response: %w", seedDomain, err)
+	}
+	for _, link := range finger.Links {
+		if link.Rel != "okta:idp" {
+			continue
+		}
+		if u, err := url.Parse(link.Href); err == nil && orgDomainPattern.MatchString(u.Hostname()) {
+			return u.Hostname(), nil
+		}
+	}
+	return "", fmt.Errorf("no Okta org signs in %s according to %s", email, seedDomain)
+}
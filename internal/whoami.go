@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+type sessionMetadata struct {
+	AssumedRoleARN    string            `json:"assumed_role_arn" yaml:"assumed_role_arn"`
+	AssumedRoleID     string            `json:"assumed_role_id" yaml:"assumed_role_id"`
+	AccountID         string            `json:"account_id" yaml:"account_id"`
+	RoleSessionName   string            `json:"role_session_name,omitempty" yaml:"role_session_name,omitempty"`
+	SourceIdentity    string            `json:"source_identity,omitempty" yaml:"source_identity,omitempty"`
+	Subject           string            `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Issuer            string            `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	SessionTags       map[string]string `json:"session_tags,omitempty" yaml:"session_tags,omitempty"`
+	TransitiveTagKeys []string          `json:"transitive_tag_keys,omitempty" yaml:"transitive_tag_keys,omitempty"`
+	Expiration        string            `json:"expiration" yaml:"expiration"`
+}
+
+func newSessionMetadata(result *sts.AssumeRoleWithSAMLOutput, attrs samlSessionAttributes) *sessionMetadata {
+	meta := &sessionMetadata{
+		RoleSessionName:   attrs.RoleSessionName,
+		SourceIdentity:    aws.StringValue(result.SourceIdentity),
+		Subject:           aws.StringValue(result.Subject),
+		Issuer:            aws.StringValue(result.Issuer),
+		SessionTags:       attrs.PrincipalTags,
+		TransitiveTagKeys: attrs.TransitiveTagKeys,
+	}
+	if meta.SourceIdentity == "" {
+		meta.SourceIdentity = attrs.SourceIdentity
+	}
+	if result.AssumedRoleUser != nil {
+		meta.AssumedRoleARN = aws.StringValue(result.AssumedRoleUser.Arn)
+		meta.AssumedRoleID = aws.StringValue(result.AssumedRoleUser.AssumedRoleId)
+		meta.AccountID = accountIDFromARN(meta.AssumedRoleARN)
+		if idx := strings.LastIndex(meta.AssumedRoleARN, "/"); idx != -1 {
+			meta.RoleSessionName = meta.AssumedRoleARN[idx+1:]
+		}
+	}
+	if result.Credentials != nil && result.Credentials.Expiration != nil {
+		meta.Expiration = result.Credentials.Expiration.Format(time.RFC3339)
+	}
+	return meta
+}
+
+func (a *Authenticator) WhoAmI(format string) error {
+	samlAssertion, roleARN, principalARN, err := a.fetchSAMLAssertionAndRole()
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.assumeRole(samlAssertion, roleARN, principalARN); err != nil {
+		return fmt.Errorf("failed to assume role: %w", err)
+	}
+
+	return printSessionMetadata(a.session, format)
+}
+
+func printSessionMetadata(meta *sessionMetadata, format string) error {
+	return printStructured(format, meta, func() error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Assumed role:\t%s\n", meta.AssumedRoleARN)
+		fmt.Fprintf(w, "Account:\t%s\n", meta.AccountID)
+		fmt.Fprintf(w, "Role session name:\t%s\n", meta.RoleSessionName)
+		fmt.Fprintf(w, "Source identity:\t%s\n", meta.SourceIdentity)
+		fmt.Fprintf(w, "Subject:\t%s\n", meta.Subject)
+		fmt.Fprintf(w, "Issuer:\t%s\n", meta.Issuer)
+		var tags []string
+		for _, key := range sortedKeys(meta.SessionTags) {
+			tags = append(tags, key+"="+meta.SessionTags[key])
+		}
+		fmt.Fprintf(w, "Session tags:\t%s\n", strings.Join(tags, ", "))
+		fmt.Fprintf(w, "Transitive tag keys:\t%s\n", strings.Join(meta.TransitiveTagKeys, ", "))
+		fmt.Fprintf(w, "Expires:\t%s\n", meta.Expiration)
+		return w.Flush()
+	})
+}